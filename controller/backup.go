@@ -0,0 +1,362 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// dumps the database to /backup, the name and size of the archive are written to the termination message so that they can be reported in the status
+const mongodumpScript = `set -e
+artifact="$(date -u +%Y%m%dT%H%M%SZ).archive.gz"
+mongodump --uri="$MONGODB_URI" --gzip --archive="/backup/$artifact"
+echo "{\"artifact\": \"$artifact\", \"size\": $(stat -c %s "/backup/$artifact")}" > /dev/termination-log
+`
+
+// removes the oldest archives of the volume until RETENTION are left, only posix options are used as the tools of the image may be busybox
+const pruneVolumeScript = `set -e
+ls -1 /backup | grep '\.archive\.gz$' | sort -r | tail -n +$((RETENTION + 1)) | while read -r old; do
+  echo "removing $old"
+  rm -f "/backup/$old"
+done
+`
+
+// uploads the archive to the bucket and removes the oldest archives of the prefix until RETENTION are left
+// the minio client image is based on ubi-micro, which ships sh and coreutils but no awk or grep, so only mc, ls, sort and tail are used
+const uploadS3Script = `set -e
+artifact="$(ls -1 /backup | sort | tail -n 1)"
+mc alias set target "$S3_ENDPOINT" "$S3_ACCESS_KEY" "$S3_SECRET_KEY" > /dev/null
+mc mb --ignore-existing "target/$S3_BUCKET"
+mc cp "/backup/$artifact" "target/$S3_BUCKET/$S3_PREFIX/$artifact"
+mc find "target/$S3_BUCKET/$S3_PREFIX" --name "*.archive.gz" | sort -r | tail -n +$((RETENTION + 1)) | while read -r old; do
+  mc rm "$old"
+done
+`
+
+// written by the mongodump container to its termination message
+type backupResult struct {
+	Artifact string `json:"artifact"`
+	Size     int64  `json:"size"`
+}
+
+// the archive is written by mongodump in an init container and then uploaded (s3) or the old archives are pruned (volume) by the main container
+func newBackupCronJob(sb *v1alpha1.SocialBook) *batchv1.CronJob {
+	backup := sb.Spec.Backup
+	secretName := sb.Name + Secret
+
+	var backoffLimit int32
+	backoffLimit = 2
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		InitContainers: []corev1.Container{
+			{
+				Name:                     "mongodump",
+				Image:                    mongoImage(sb),
+				Command:                  []string{"sh", "-c", mongodumpScript},
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+				Env: []corev1.EnvVar{
+					{
+						Name: "MONGODB_URI",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: secretName,
+								},
+								Key: "mongodb-uri",
+							},
+						},
+					},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "backup",
+						MountPath: "/backup",
+					},
+				},
+			},
+		},
+	}
+
+	retention := corev1.EnvVar{
+		Name:  "RETENTION",
+		Value: strconv.Itoa(int(backupRetention(sb))),
+	}
+
+	if backup.Volume != nil {
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: "backup",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: backup.Volume.ClaimName,
+					},
+				},
+			},
+		}
+
+		podSpec.Containers = []corev1.Container{
+			{
+				Name:    "prune",
+				Image:   mongoImage(sb),
+				Command: []string{"sh", "-c", pruneVolumeScript},
+				Env:     []corev1.EnvVar{retention},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "backup",
+						MountPath: "/backup",
+					},
+				},
+			},
+		}
+	} else if backup.S3 != nil {
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: "backup",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			},
+		}
+
+		podSpec.Containers = []corev1.Container{
+			{
+				Name:    "upload",
+				Image:   DefaultBackupUploadImage,
+				Command: []string{"sh", "-c", uploadS3Script},
+				Env:     append(s3Env(sb), retention),
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "backup",
+						MountPath: "/backup",
+					},
+				},
+			},
+		}
+	}
+
+	mountDatabaseCA(sb, &podSpec)
+	setImagePullOptions(sb, &podSpec)
+
+	cronJob := &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "CronJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            sb.Name + Backup,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          backup.Schedule,
+			Suspend:           &backup.Suspend,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						SocialBookLabel: sb.Name,
+					},
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								"app":           sb.Name + Backup,
+								SocialBookLabel: sb.Name,
+							},
+						},
+						Spec: podSpec,
+					},
+				},
+			},
+		},
+	}
+
+	return cronJob
+}
+
+// endpoint, bucket, prefix and credentials of the s3 target, also used by the restore jobs
+func s3Env(sb *v1alpha1.SocialBook) []corev1.EnvVar {
+	s3 := sb.Spec.Backup.S3
+
+	prefix := s3.Prefix
+	if prefix == "" {
+		prefix = sb.Namespace + "/" + sb.Name
+	}
+
+	return []corev1.EnvVar{
+		{
+			Name:  "S3_ENDPOINT",
+			Value: s3.Endpoint,
+		},
+		{
+			Name:  "S3_BUCKET",
+			Value: s3.Bucket,
+		},
+		{
+			Name:  "S3_PREFIX",
+			Value: prefix,
+		},
+		{
+			Name: "S3_ACCESS_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: s3.CredentialsSecret,
+					},
+					Key: "accessKey",
+				},
+			},
+		},
+		{
+			Name: "S3_SECRET_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: s3.CredentialsSecret,
+					},
+					Key: "secretKey",
+				},
+			},
+		},
+	}
+}
+
+func backupRetention(sb *v1alpha1.SocialBook) int32 {
+	if sb.Spec.Backup.Retention > 0 {
+		return sb.Spec.Backup.Retention
+	}
+
+	return DefaultBackupRetention
+}
+
+// creates the backup cronjob, or deletes it when backups are disabled, and reports the last successful backup in the status
+// the cronjob is suspended while restoring is set
+func (c *Controller) handleBackup(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, restoring bool) error {
+	cronJobName := sb.Name + Backup
+	cronJob, err := c.cronJobLister.CronJobs(sb.Namespace).Get(cronJobName)
+
+	if sb.Spec.Backup == nil {
+		if err != nil || !metav1.IsControlledBy(cronJob, sb) {
+			return nil
+		}
+
+		propagation := metav1.DeletePropagationBackground
+		err = c.clientset.BatchV1().CronJobs(sb.Namespace).Delete(context.Background(), cronJobName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "Deleted", "Deleted CronJob %s, backups are disabled", cronJobName)
+		return nil
+	}
+
+	backup := sb.Spec.Backup
+	if (backup.S3 == nil) == (backup.Volume == nil) {
+		return fmt.Errorf("exactly one of backup.s3 and backup.volume has to be set")
+	}
+
+	desiredCronJob := newBackupCronJob(sb)
+
+	// no backup is started while a restore replaces the database
+	if restoring {
+		suspend := true
+		desiredCronJob.Spec.Suspend = &suspend
+	}
+
+	if err = c.handleResource(err, cronJob, sb, desiredCronJob); err != nil {
+		return err
+	}
+
+	if result, finished := c.lastBackup(sb); finished != nil {
+		if sbCopy.Status.Backup == nil || sbCopy.Status.Backup.LastSuccessfulTime == nil || sbCopy.Status.Backup.LastSuccessfulTime.Before(finished) {
+			sbCopy.Status.Backup = &v1alpha1.BackupStatus{
+				LastSuccessfulTime: finished,
+				LastArtifact:       result.Artifact,
+				LastSize:           result.Size,
+			}
+		}
+	}
+
+	return nil
+}
+
+// result and completion time of the latest successful backup job which still exists, nil if there is none
+func (c *Controller) lastBackup(sb *v1alpha1.SocialBook) (backupResult, *metav1.Time) {
+	var result backupResult
+
+	jobs, err := c.jobLister.Jobs(sb.Namespace).List(labels.SelectorFromSet(labels.Set{SocialBookLabel: sb.Name}))
+	if err != nil {
+		return result, nil
+	}
+
+	// newest first
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp)
+	})
+
+	for _, job := range jobs {
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "CronJob" || owner.Name != sb.Name+Backup || job.Status.CompletionTime == nil || job.Status.Succeeded == 0 {
+			continue
+		}
+
+		pods, err := c.podLister.Pods(sb.Namespace).List(labels.SelectorFromSet(labels.Set{"job-name": job.Name}))
+		if err != nil {
+			continue
+		}
+
+		for _, pod := range pods {
+			for _, status := range pod.Status.InitContainerStatuses {
+				if status.Name != "mongodump" || status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 {
+					continue
+				}
+
+				if err := json.Unmarshal([]byte(status.State.Terminated.Message), &result); err == nil {
+					return result, job.Status.CompletionTime
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// checks if a job of the backup cronjob hasn't finished yet
+func (c *Controller) backupRunning(sb *v1alpha1.SocialBook) (bool, error) {
+	jobs, err := c.jobLister.Jobs(sb.Namespace).List(labels.SelectorFromSet(labels.Set{SocialBookLabel: sb.Name}))
+	if err != nil {
+		return false, err
+	}
+
+	for _, job := range jobs {
+		owner := metav1.GetControllerOf(job)
+		if owner == nil || owner.Kind != "CronJob" || owner.Name != sb.Name+Backup {
+			continue
+		}
+
+		finished := false
+		for _, condition := range job.Status.Conditions {
+			if condition.Status == corev1.ConditionTrue && (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) {
+				finished = true
+			}
+		}
+
+		if !finished {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
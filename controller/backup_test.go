@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func envValue(env []corev1.EnvVar, name string) (corev1.EnvVar, bool) {
+	for _, e := range env {
+		if e.Name == name {
+			return e, true
+		}
+	}
+
+	return corev1.EnvVar{}, false
+}
+
+func TestNewBackupCronJobVolume(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Backup = &v1alpha1.BackupSpec{
+		Schedule: "0 2 * * *",
+		Volume:   &v1alpha1.VolumeBackupTarget{ClaimName: "backups"},
+	}
+
+	cronJob := newBackupCronJob(sb)
+	if cronJob.Name != "sb-backup" || cronJob.Spec.Schedule != "0 2 * * *" {
+		t.Errorf("unexpected cronjob %s with schedule %s", cronJob.Name, cronJob.Spec.Schedule)
+	}
+
+	if !metav1.IsControlledBy(cronJob, sb) {
+		t.Errorf("expected the cronjob to be owned by the SocialBook")
+	}
+
+	podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+	if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != "mongodump" {
+		t.Fatalf("expected the mongodump init container, got %v", podSpec.InitContainers)
+	}
+
+	if len(podSpec.Containers) != 1 || podSpec.Containers[0].Name != "prune" {
+		t.Fatalf("expected the prune container, got %v", podSpec.Containers)
+	}
+
+	if retention, _ := envValue(podSpec.Containers[0].Env, "RETENTION"); retention.Value != "7" {
+		t.Errorf("expected the default retention 7, got %q", retention.Value)
+	}
+
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].PersistentVolumeClaim == nil || podSpec.Volumes[0].PersistentVolumeClaim.ClaimName != "backups" {
+		t.Errorf("expected the backups claim to be mounted, got %v", podSpec.Volumes)
+	}
+}
+
+func TestNewBackupCronJobS3(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Backup = &v1alpha1.BackupSpec{
+		Schedule:  "0 2 * * *",
+		Retention: 3,
+		S3: &v1alpha1.S3BackupTarget{
+			Endpoint:          "http://minio.minio:9000",
+			Bucket:            "backups",
+			CredentialsSecret: "s3-credentials",
+		},
+	}
+
+	podSpec := newBackupCronJob(sb).Spec.JobTemplate.Spec.Template.Spec
+	if len(podSpec.Containers) != 1 || podSpec.Containers[0].Name != "upload" {
+		t.Fatalf("expected the upload container, got %v", podSpec.Containers)
+	}
+
+	upload := podSpec.Containers[0]
+	if upload.Image != DefaultBackupUploadImage {
+		t.Errorf("expected image %s, got %s", DefaultBackupUploadImage, upload.Image)
+	}
+
+	if retention, _ := envValue(upload.Env, "RETENTION"); retention.Value != "3" {
+		t.Errorf("expected the retention 3, got %q", retention.Value)
+	}
+
+	if prefix, _ := envValue(upload.Env, "S3_PREFIX"); prefix.Value != "dev/sb" {
+		t.Errorf("expected the default prefix dev/sb, got %q", prefix.Value)
+	}
+
+	accessKey, ok := envValue(upload.Env, "S3_ACCESS_KEY")
+	if !ok || accessKey.ValueFrom == nil || accessKey.ValueFrom.SecretKeyRef.Name != "s3-credentials" {
+		t.Errorf("expected the access key from s3-credentials, got %v", accessKey)
+	}
+
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected an empty dir volume, got %v", podSpec.Volumes)
+	}
+}
+
+// stand-in for the minio client, the objects of the target alias are stored as files in $S3_ROOT
+const fakeMinioClient = `#!/bin/sh
+set -e
+case "$1" in
+alias) ;;
+mb) mkdir -p "$S3_ROOT/${3#target/}" ;;
+cp) mkdir -p "$(dirname "$S3_ROOT/${3#target/}")" && cp "$2" "$S3_ROOT/${3#target/}" ;;
+find) cd "$S3_ROOT" && find "${2#target/}" -type f -name "$4" | sed 's|^|target/|' ;;
+rm) rm "$S3_ROOT/${2#target/}" ;;
+*) echo "unsupported command $1" >&2; exit 1 ;;
+esac
+`
+
+// runs a script of the backup job with /backup replaced by the directory
+func runBackupScript(t *testing.T, script string, backupDir string, env ...string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "mc"), []byte(fakeMinioClient), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(script, "/backup", backupDir))
+	cmd.Env = append(os.Environ(), append(env, "PATH="+binDir+":"+os.Getenv("PATH"))...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err, output)
+	}
+}
+
+func writeArchives(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listArchives(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestPruneVolumeScript(t *testing.T) {
+	dir := t.TempDir()
+	writeArchives(t, dir, "20240101T020000Z.archive.gz", "20240102T020000Z.archive.gz", "20240103T020000Z.archive.gz", "notes.txt")
+
+	runBackupScript(t, pruneVolumeScript, dir, "RETENTION=2")
+
+	expected := []string{"20240102T020000Z.archive.gz", "20240103T020000Z.archive.gz", "notes.txt"}
+	if names := listArchives(t, dir); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestUploadS3Script(t *testing.T) {
+	backupDir, root := t.TempDir(), t.TempDir()
+	writeArchives(t, backupDir, "20240104T020000Z.archive.gz")
+	writeArchives(t, filepath.Join(root, "backups", "dev", "sb"), "20240101T020000Z.archive.gz", "20240102T020000Z.archive.gz", "20240103T020000Z.archive.gz")
+
+	runBackupScript(t, uploadS3Script, backupDir, "S3_ROOT="+root, "S3_BUCKET=backups", "S3_PREFIX=dev/sb", "RETENTION=2")
+
+	expected := []string{"20240103T020000Z.archive.gz", "20240104T020000Z.archive.gz"}
+	if names := listArchives(t, filepath.Join(root, "backups", "dev", "sb")); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+// pod of a backup job with the termination message of the mongodump container
+func newTestBackupPod(sb *v1alpha1.SocialBook, jobName string, exitCode int32, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName + "-pod",
+			Namespace: sb.Namespace,
+			Labels:    map[string]string{"job-name": jobName},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "mongodump",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: exitCode,
+							Message:  message,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestLastBackup(t *testing.T) {
+	sb := newTestSocialBook()
+
+	now := time.Now()
+	succeeded := newTestBackupJob(sb, "sb-backup-1", now.Add(-time.Hour), true)
+	failed := newTestBackupJob(sb, "sb-backup-2", now.Add(-time.Minute), false)
+
+	c := newTestController(t, succeeded, failed,
+		newTestBackupPod(sb, "sb-backup-1", 0, `{"artifact": "20240101T020000Z.archive.gz", "size": 42}`),
+		newTestBackupPod(sb, "sb-backup-2", 1, ""),
+	)
+
+	result, finished := c.lastBackup(sb)
+	if finished == nil {
+		t.Fatal("expected a successful backup")
+	}
+
+	if !finished.Equal(succeeded.Status.CompletionTime) {
+		t.Errorf("expected completion time %s, got %s", succeeded.Status.CompletionTime, finished)
+	}
+
+	if result.Artifact != "20240101T020000Z.archive.gz" || result.Size != 42 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestLastBackupNone(t *testing.T) {
+	sb := newTestSocialBook()
+
+	// the termination message is missing, for example because the pod was already removed
+	c := newTestController(t, newTestBackupJob(sb, "sb-backup-1", time.Now(), true))
+
+	if _, finished := c.lastBackup(sb); finished != nil {
+		t.Errorf("expected no backup, got %s", finished)
+	}
+}
+
+func TestHandleBackupDisabled(t *testing.T) {
+	sb := newTestSocialBook()
+
+	enabled := sb.DeepCopy()
+	enabled.Spec.Backup = &v1alpha1.BackupSpec{
+		Schedule: "0 2 * * *",
+		Volume:   &v1alpha1.VolumeBackupTarget{ClaimName: "backups"},
+	}
+
+	c := newTestController(t, newBackupCronJob(enabled))
+
+	if err := c.handleBackup(sb, sb.DeepCopy(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := c.clientset.BatchV1().CronJobs("dev").Get(context.Background(), "sb-backup", metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the cronjob to be deleted, got %v", err)
+	}
+}
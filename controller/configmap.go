@@ -1,34 +1,91 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
 	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// only non sensitive values are stored in the config map, credentials are stored in the secret (see secret.go)
 func newConfigMap(sb *v1alpha1.SocialBook) *corev1.ConfigMap {
 	cmName := sb.Name + ConfigMap
-	mongodbUri := "mongodb://" + sb.Spec.MongoUsername + ":" + sb.Spec.MongoPassword + "@" + sb.Name + MongoDB + ":27017"
 
 	// config map
 	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            cmName,
 			Namespace:       sb.Namespace,
 			OwnerReferences: setOwnerReference(sb),
 		},
 		Data: map[string]string{
-			"mongo-root-username": sb.Spec.MongoUsername, // mongo username
-			"mongo-root-password": sb.Spec.MongoPassword, // mongo password
-			"port":                sb.Spec.Port,          // container port
-			"secret":              sb.Spec.JwtSecret,     // any random string (used for jwt token)
-			"stripe-api-key":      sb.Spec.StripeApiKey,  // api key used for payments
-			"user-email":          sb.Spec.EmailId,       // email id to send verification emails
-			"user-pwd":            sb.Spec.Password,      // password for email id
-			"client-url":          sb.Spec.ClientUrl,     // redirect url after email verification
-			"mongodb-uri":         mongodbUri,
+			"port":       sb.Spec.Port,      // container port
+			"user-email": sb.Spec.EmailId,   // email id to send verification emails
+			"client-url": sb.Spec.ClientUrl, // redirect url after email verification
 		},
 	}
 
 	return cm
 }
+
+// hash of the data of the config map and secret, keys are sorted so that the hash is stable
+func configHash(cm *corev1.ConfigMap, secret *corev1.Secret) string {
+	hash := sha256.New()
+
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hash.Write([]byte(key + "=" + cm.Data[key] + "\n"))
+	}
+
+	keys = make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hash.Write([]byte(key + "="))
+		hash.Write(secret.Data[key])
+		hash.Write([]byte("\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hash of the values of the secret used by mongodb, the uri is left out so that scaling the replica set doesn't restart the members
+func mongoConfigHash(secret *corev1.Secret) string {
+	hash := sha256.New()
+
+	for _, key := range []string{"mongo-root-username", "mongo-root-password", "mongo-keyfile"} {
+		hash.Write([]byte(key + "="))
+		hash.Write(secret.Data[key])
+		hash.Write([]byte("\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hash of the connection values of an external database
+func externalDatabaseHash(secret *corev1.Secret) string {
+	hash := sha256.New()
+
+	for _, key := range []string{"mongodb-uri", "mongodb-ca"} {
+		hash.Write([]byte(key + "="))
+		hash.Write(secret.Data[key])
+		hash.Write([]byte("\n"))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestConfigHash(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{"port": "3000", "email": "a@b.c", "host": "sb"}}
+	secret := &corev1.Secret{Data: map[string][]byte{"mongo-root-username": []byte("root"), "mongo-root-password": []byte("secret")}}
+
+	hash := configHash(cm, secret)
+	for i := 0; i < 10; i++ {
+		// map iteration order is random, the hash must not depend on it
+		if configHash(cm.DeepCopy(), secret.DeepCopy()) != hash {
+			t.Fatal("expected the hash to be stable")
+		}
+	}
+
+	changed := cm.DeepCopy()
+	changed.Data["port"] = "3001"
+	if configHash(changed, secret) == hash {
+		t.Error("expected the hash to change with a config map value")
+	}
+
+	changedSecret := secret.DeepCopy()
+	changedSecret.Data["mongo-root-password"] = []byte("other")
+	if configHash(cm, changedSecret) == hash {
+		t.Error("expected the hash to change with a secret value")
+	}
+}
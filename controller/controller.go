@@ -2,87 +2,216 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
 	"github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned"
+	customScheme "github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned/scheme"
 	informers "github.com/ashwin901/social-book-operator/pkg/client/informers/externalversions/ashwin901.operators/v1alpha1"
 	lister "github.com/ashwin901/social-book-operator/pkg/client/listers/ashwin901.operators/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	kubeInformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	appsLister "k8s.io/client-go/listers/apps/v1"
+	batchLister "k8s.io/client-go/listers/batch/v1"
 	coreLister "k8s.io/client-go/listers/core/v1"
 	networkingLister "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/csaupgrade"
 	"k8s.io/client-go/util/workqueue"
 )
 
 const (
-	ConfigMap             = "-cm"
-	Service               = "-svc"
-	PersistentVolume      = "-pv"
-	PersistentVolumeClaim = "-pvc"
-	NetworkPolicy         = "-np"
-	Deployment            = "-dep"
-	MongoDB               = "-mongo"
-	SocialBook            = "-sb"
-	Kind                  = "SocialBook"
-	Success               = "Success"
-	Pending               = "Pending"
-	Failure               = "Failed"
-	Image                 = "ashwin901/social-book-server"
+	ConfigMap                 = "-cm"
+	Secret                    = "-secret"
+	Service                   = "-svc"
+	PersistentVolume          = "-pv"
+	PersistentVolumeClaim     = "-pvc"
+	NetworkPolicy             = "-np"
+	Deployment                = "-dep"
+	MongoDB                   = "-mongo"
+	Headless                  = "-hl"
+	ReplicaSetJob             = "-rs"
+	DatabaseCheck             = "-check"
+	Backup                    = "-backup"
+	Restore                   = "-restore"
+	SocialBook                = "-sb"
+	Kind                      = "SocialBook"
+	RestoreKind               = "SocialBookRestore"
+	NamespaceLabel            = "ashwin901.operators/socialbook-namespace"
+	SocialBookLabel           = "ashwin901.operators/socialbook"
+	SnapshotTriggerLabel      = "ashwin901.operators/snapshot-trigger"
+	ConfigHashAnnotation      = "ashwin901.operators/config-hash"
+	SnapshotRequestAnnotation = "ashwin901.operators/snapshot-request"
+	FieldManager              = "social-book-operator"
+	Success                   = "Success"
+	Pending                   = "Pending"
+	Failure                   = "Failed"
+	Image                     = "ashwin901/social-book-server"
+	MongoImage                = "mongo:8.0"
+	RequeueInterval           = 10 * time.Second
+	StuckWorkerTimeout        = 5 * time.Minute
+	DefaultMongoUsername      = "admin"
+	DefaultStorageSize        = "1Gi"
+	MongoDataVolume           = "data"
+	ReplicaSetName            = "rs0"
+	BackupUploadImage         = "minio/mc:RELEASE.2024-11-21T17-21-54Z"
+	DefaultBackupRetention    = 7
+	DefaultSnapshotRetention  = 7
+	DatabaseCAPath            = "/etc/socialbook/mongodb-ca.pem"
+	DatabaseCheckInterval     = 5 * time.Minute
+	MinMongoVersion           = 5 // first major version of mongodb whose image ships mongosh
 )
 
+// returned when a resource with the same name exists but is controlled by some other resource
+var errResourceExists = stderrors.New("resource already exists")
+
 type Controller struct {
 	clientset           kubernetes.Interface
 	customClientset     versioned.Interface
+	dynamicClient       dynamic.Interface
 	socialbookLister    lister.SocialBookLister
 	deploymentLister    appsLister.DeploymentLister
+	statefulSetLister   appsLister.StatefulSetLister
 	serviceLister       coreLister.ServiceLister
 	configMapLister     coreLister.ConfigMapLister
+	secretLister        coreLister.SecretLister
 	pvLister            coreLister.PersistentVolumeLister
 	pvcLister           coreLister.PersistentVolumeClaimLister
 	networkPolicyLister networkingLister.NetworkPolicyLister
+	podLister           coreLister.PodLister
+	jobLister           batchLister.JobLister
+	cronJobLister       batchLister.CronJobLister
+	restoreLister       lister.SocialBookRestoreLister
 	socialbookSynced    cache.InformerSynced
 	deploymentSynced    cache.InformerSynced
+	statefulSetSynced   cache.InformerSynced
 	serviceSynced       cache.InformerSynced
 	configMapSynced     cache.InformerSynced
+	secretSynced        cache.InformerSynced
 	pvSynced            cache.InformerSynced
 	pvcSynced           cache.InformerSynced
 	networkPolicySynced cache.InformerSynced
+	podSynced           cache.InformerSynced
+	jobSynced           cache.InformerSynced
+	cronJobSynced       cache.InformerSynced
+	restoreSynced       cache.InformerSynced
 	queue               workqueue.RateLimitingInterface
+	recorder            record.EventRecorder
+	processing          map[string]time.Time // items currently being reconciled and when they were started (liveness)
+	processingLock      sync.Mutex
+	selector            labels.Selector // SocialBooks reconciled by this instance of the operator (shard)
+	clusterScoped       bool            // all namespaces are watched, cluster scoped resources (pvs) are only used then
 }
 
-func NewController(clientset kubernetes.Interface, customClientset versioned.Interface, socialBookInformer informers.SocialBookInformer, factory kubeInformers.SharedInformerFactory) *Controller {
+// socialBookInformers, restoreInformers and factories have one entry for every watched namespace (a single one when all namespaces are watched)
+// selector is the label selector the SocialBook informers are filtered with, clusterScoped is false when only some namespaces are watched
+func NewController(clientset kubernetes.Interface, customClientset versioned.Interface, dynamicClient dynamic.Interface, socialBookInformers []informers.SocialBookInformer, restoreInformers []informers.SocialBookRestoreInformer, factories []kubeInformers.SharedInformerFactory, selector labels.Selector, clusterScoped bool) *Controller {
+
+	// SocialBook types are added to the scheme so that events can be recorded for them
+	utilruntime.Must(customScheme.AddToScheme(scheme.Scheme))
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(log.Printf)
+	eventBroadcaster.StartRecordingToSink(&typedCoreV1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+
+	var socialbooks, restores, deployments, statefulSets, services, configMaps, secrets, pvcs, networkPolicies, pods, jobs, cronJobs namespacedInformers
+
+	for _, socialBookInformer := range socialBookInformers {
+		socialbooks = append(socialbooks, socialBookInformer.Informer())
+	}
+
+	for _, restoreInformer := range restoreInformers {
+		restores = append(restores, restoreInformer.Informer())
+	}
+
+	for _, factory := range factories {
+		deployments = append(deployments, factory.Apps().V1().Deployments().Informer())
+		statefulSets = append(statefulSets, factory.Apps().V1().StatefulSets().Informer())
+		services = append(services, factory.Core().V1().Services().Informer())
+		configMaps = append(configMaps, factory.Core().V1().ConfigMaps().Informer())
+		secrets = append(secrets, factory.Core().V1().Secrets().Informer())
+		pvcs = append(pvcs, factory.Core().V1().PersistentVolumeClaims().Informer())
+		networkPolicies = append(networkPolicies, factory.Networking().V1().NetworkPolicies().Informer())
+		pods = append(pods, factory.Core().V1().Pods().Informer())
+		jobs = append(jobs, factory.Batch().V1().Jobs().Informer())
+		cronJobs = append(cronJobs, factory.Batch().V1().CronJobs().Informer())
+	}
+
+	// pvs are cluster scoped, the namespace of the factory is ignored for them, they are not watched when only some namespaces
+	// are watched so that the namespaced install doesn't need a cluster role (the lister is then always empty)
+	var pvs namespacedInformers
+	if clusterScoped {
+		pvs = namespacedInformers{factories[0].Core().V1().PersistentVolumes().Informer()}
+	}
 
 	controller := &Controller{
 		clientset:           clientset,
 		customClientset:     customClientset,
-		socialbookLister:    socialBookInformer.Lister(),
-		deploymentLister:    factory.Apps().V1().Deployments().Lister(),
-		serviceLister:       factory.Core().V1().Services().Lister(),
-		configMapLister:     factory.Core().V1().ConfigMaps().Lister(),
-		pvLister:            factory.Core().V1().PersistentVolumes().Lister(),
-		pvcLister:           factory.Core().V1().PersistentVolumeClaims().Lister(),
-		networkPolicyLister: factory.Networking().V1().NetworkPolicies().Lister(),
-		socialbookSynced:    socialBookInformer.Informer().HasSynced,
-		deploymentSynced:    factory.Apps().V1().Deployments().Informer().HasSynced,
-		serviceSynced:       factory.Core().V1().Services().Informer().HasSynced,
-		configMapSynced:     factory.Core().V1().ConfigMaps().Informer().HasSynced,
-		pvSynced:            factory.Core().V1().PersistentVolumes().Informer().HasSynced,
-		pvcSynced:           factory.Core().V1().PersistentVolumeClaims().Informer().HasSynced,
-		networkPolicySynced: factory.Networking().V1().NetworkPolicies().Informer().HasSynced,
+		dynamicClient:       dynamicClient,
+		socialbookLister:    lister.NewSocialBookLister(socialbooks.Indexer()),
+		deploymentLister:    appsLister.NewDeploymentLister(deployments.Indexer()),
+		statefulSetLister:   appsLister.NewStatefulSetLister(statefulSets.Indexer()),
+		serviceLister:       coreLister.NewServiceLister(services.Indexer()),
+		configMapLister:     coreLister.NewConfigMapLister(configMaps.Indexer()),
+		secretLister:        coreLister.NewSecretLister(secrets.Indexer()),
+		pvLister:            coreLister.NewPersistentVolumeLister(pvs.Indexer()),
+		pvcLister:           coreLister.NewPersistentVolumeClaimLister(pvcs.Indexer()),
+		networkPolicyLister: networkingLister.NewNetworkPolicyLister(networkPolicies.Indexer()),
+		podLister:           coreLister.NewPodLister(pods.Indexer()),
+		jobLister:           batchLister.NewJobLister(jobs.Indexer()),
+		cronJobLister:       batchLister.NewCronJobLister(cronJobs.Indexer()),
+		restoreLister:       lister.NewSocialBookRestoreLister(restores.Indexer()),
+		socialbookSynced:    socialbooks.HasSynced,
+		deploymentSynced:    deployments.HasSynced,
+		statefulSetSynced:   statefulSets.HasSynced,
+		serviceSynced:       services.HasSynced,
+		configMapSynced:     configMaps.HasSynced,
+		secretSynced:        secrets.HasSynced,
+		pvSynced:            pvs.HasSynced,
+		pvcSynced:           pvcs.HasSynced,
+		networkPolicySynced: networkPolicies.HasSynced,
+		podSynced:           pods.HasSynced,
+		jobSynced:           jobs.HasSynced,
+		cronJobSynced:       cronJobs.HasSynced,
+		restoreSynced:       restores.HasSynced,
 		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "socialbookController"),
+		recorder:            eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: FieldManager}),
+		processing:          map[string]time.Time{},
+		selector:            selector,
+		clusterScoped:       clusterScoped,
 	}
 
+	// metrics computed from the listers when they are scraped
+	prometheus.MustRegister(&stateCollector{controller: controller})
+
 	// when socialbook custom resource is deleted all items created by the controller because of it are also deleted (because of owner reference)
 	// so no need to handle delete event
-	socialBookInformer.Informer().AddEventHandler(
+	socialbooks.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: controller.addItemToQueue,
 			UpdateFunc: func(oldObj, newObj interface{}) {
@@ -98,30 +227,79 @@ func NewController(clientset kubernetes.Interface, customClientset versioned.Int
 	)
 
 	// adding event handler functions for all the other resources
-	factory.Apps().V1().Deployments().Informer().AddEventHandler(
+	deployments.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
-	factory.Core().V1().Services().Informer().AddEventHandler(
+	statefulSets.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
-	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(
+	services.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
-	factory.Core().V1().PersistentVolumes().Informer().AddEventHandler(
+	configMaps.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
-	factory.Core().V1().PersistentVolumeClaims().Informer().AddEventHandler(
+	secrets.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
-	factory.Networking().V1().NetworkPolicies().Informer().AddEventHandler(
+	// secrets referenced in the spec are not owned by the SocialBook, so changes to them are handled separately
+	secrets.AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: controller.handleReferencedSecret,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				old := oldObj.(metav1.Object)
+				new := newObj.(metav1.Object)
+				// if it has the same resource version we ignore it
+				if old.GetResourceVersion() == new.GetResourceVersion() {
+					return
+				}
+				controller.handleReferencedSecret(newObj)
+			},
+			DeleteFunc: controller.handleReferencedSecret,
+		},
+	)
+
+	pvs.AddEventHandler(
 		controller.getEventHandlerFunctions(),
 	)
 
+	pvcs.AddEventHandler(
+		controller.getEventHandlerFunctions(),
+	)
+
+	networkPolicies.AddEventHandler(
+		controller.getEventHandlerFunctions(),
+	)
+
+	jobs.AddEventHandler(
+		controller.getEventHandlerFunctions(),
+	)
+
+	cronJobs.AddEventHandler(
+		controller.getEventHandlerFunctions(),
+	)
+
+	// restores reference the SocialBook by name, the SocialBook is reconciled when they change
+	restores.AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: controller.handleRestore,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				old := oldObj.(metav1.Object)
+				new := newObj.(metav1.Object)
+				// if it has the same resource version we ignore it
+				if old.GetResourceVersion() == new.GetResourceVersion() {
+					return
+				}
+				controller.handleRestore(newObj)
+			},
+		},
+	)
+
 	return controller
 }
 
@@ -141,29 +319,57 @@ func (c *Controller) getEventHandlerFunctions() cache.ResourceEventHandlerFuncs
 	}
 }
 
-func (c *Controller) Run(ch chan struct{}) {
-
-	log.Printf("Starting Controller")
+// workers is the number of SocialBooks reconciled in parallel, the queue never hands out the same key to two workers at the same time
+// once ch is closed the workers stop taking new items and Run waits up to shutdownTimeout for the items being processed to finish
+func (c *Controller) Run(workers int, shutdownTimeout time.Duration, ch <-chan struct{}) {
 
-	defer c.queue.ShutDown()
+	log.Printf("Starting Controller with %d workers", workers)
 
-	if !cache.WaitForCacheSync(ch, c.socialbookSynced, c.configMapSynced, c.pvSynced, c.pvcSynced, c.serviceSynced, c.deploymentSynced, c.networkPolicySynced) {
+	if !cache.WaitForCacheSync(ch, c.socialbookSynced, c.configMapSynced, c.secretSynced, c.pvSynced, c.pvcSynced, c.serviceSynced, c.deploymentSynced, c.statefulSetSynced, c.networkPolicySynced, c.podSynced, c.jobSynced, c.cronJobSynced, c.restoreSynced) {
 		log.Printf("Cache not synced")
+		c.queue.ShutDown()
 		return
 	}
 
-	go wait.Until(c.worker, 1*time.Second, ch)
+	// a worker only returns once its current reconcile is done, so the running workers are the in-flight reconciles after ch is closed
+	var running sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			wait.Until(func() { c.worker(ch) }, 1*time.Second, ch)
+		}()
+	}
 
 	<-ch
+
+	log.Printf("Shutting down Controller, waiting up to %s for running reconciles", shutdownTimeout)
+
+	// workers waiting for an item return right away, the items left in the queue are reconciled by the next leader
+	c.queue.ShutDown()
+
+	stopped := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		log.Printf("Controller stopped")
+	case <-time.After(shutdownTimeout):
+		log.Printf("Timed out while waiting for running reconciles to finish")
+	}
 }
 
-func (c *Controller) worker() {
-	for c.processItem() {
+func (c *Controller) worker(ch <-chan struct{}) {
+	for c.processItem(ch) {
 
 	}
 }
 
-func (c *Controller) processItem() bool {
+func (c *Controller) processItem(ch <-chan struct{}) bool {
 	item, shutdown := c.queue.Get()
 
 	//queue is no longer used
@@ -173,6 +379,13 @@ func (c *Controller) processItem() bool {
 
 	defer c.queue.Done(item)
 
+	// the controller is stopping, no new reconcile is started
+	select {
+	case <-ch:
+		return false
+	default:
+	}
+
 	key, ok := item.(string)
 
 	if !ok {
@@ -181,8 +394,15 @@ func (c *Controller) processItem() bool {
 		return true
 	}
 
-	if c.reconcile(key) != nil {
+	c.startProcessing(key)
+	start := time.Now()
+	err := c.reconcile(key)
+	reconcileDuration.Observe(time.Since(start).Seconds())
+	c.doneProcessing(key)
+
+	if err != nil {
 		// requeue the item if there were any errors
+		reconcileErrors.Inc()
 		c.queue.AddRateLimited(item)
 		return true
 	}
@@ -206,178 +426,512 @@ func (c *Controller) reconcile(key string) error {
 		if errors.IsNotFound(err) {
 			return nil // object not present, so no need to requeue
 		}
-		log.Printf("Error %s while getting %s from the lister", err.Error(), sb.Name)
+		log.Printf("Error %s while getting %s from the lister", err.Error(), name)
 		return err
 	}
 
+	// the image of mongodb the SocialBook runs is kept when mongo.image isn't set, the resources are built from a copy which
+	// has it in the status while the status is compared with the one of the lister
+	current := sb
+	if image := c.deployedMongoImage(sb); image != sb.Status.MongoImage {
+		sb = sb.DeepCopy()
+		sb.Status.MongoImage = image
+	}
+
 	// making a copy to update status
 	sbCopy := sb.DeepCopy()
 	sbCopy.Status.MongoDB = Pending
 	sbCopy.Status.SocialBook = Pending
 
-	defer c.updateSocialbookStatus(sbCopy)
+	// status is computed from the state of the resources once all of them are handled
+	// pods are not owned by the SocialBook, so it is checked again after some time until everything is ready
+	defer func() {
+		if err != nil {
+			c.recorder.Event(sb, corev1.EventTypeWarning, eventReason(err), err.Error())
+		}
+
+		if !c.setStatus(sb, sbCopy, err) && err == nil {
+			c.queue.AddAfter(key, RequeueInterval)
+		}
+		c.updateSocialbookStatus(current, sbCopy)
+	}()
+
+	// creating the config map and secret used by both mongodb and socialbook
+	configHash, mongoHash, err := c.handleConfiguration(sb, sbCopy)
+	if err != nil {
+		log.Printf("Error %s while creating configuration for %s", err.Error(), sb.Name)
+		sbCopy.Status.MongoDB = Failure
+		sbCopy.Status.SocialBook = Failure
+		return err
+	}
 
 	// creating all the resources required for mongodb
-	if err = c.handleMongoDbDeployment(sb, sbCopy); err != nil {
+	if err = c.handleMongoDbDeployment(sb, sbCopy, mongoHash); err != nil {
 		log.Printf("Error %s while creating MongoDB deployment for %s", err.Error(), sb.Name)
 		sbCopy.Status.MongoDB = Failure
 		return err
 	}
 
+	// restoring backups, the socialbook deployment is scaled down and the backups are suspended while the database is restored
+	restoring, err := c.handleRestores(sb)
+	if err != nil {
+		log.Printf("Error %s while restoring a backup for %s", err.Error(), sb.Name)
+		return err
+	}
+
 	// creating resources for socialbook
-	if err = c.handleSocialBookDeployment(sb, sbCopy); err != nil {
+	if err = c.handleSocialBookDeployment(sb, sbCopy, configHash, restoring); err != nil {
 		log.Printf("Error %s while creating SocialBook deployment for %s", err.Error(), sb.Name)
 		sbCopy.Status.SocialBook = Failure
 		return err
 	}
 
+	// scheduling the backups of mongodb
+	if err = c.handleBackup(sb, sbCopy, restoring); err != nil {
+		log.Printf("Error %s while creating the backup cronjob for %s", err.Error(), sb.Name)
+		return err
+	}
+
+	// taking the volume snapshots of mongodb
+	if err = c.handleSnapshots(sb, sbCopy); err != nil {
+		log.Printf("Error %s while taking a volume snapshot of %s", err.Error(), sb.Name)
+		return err
+	}
+
 	log.Printf("MongoDB and SocalBook successfully deployed for %s", sb.Name)
 
 	return nil
 }
 
-// creating a pv, pvc, deployment and service for MongoDB
-func (c *Controller) handleMongoDbDeployment(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) error {
+// creating the config map and secret, the returned hashes of their data are used to roll the socialbook and mongodb pods when the configuration changes
+func (c *Controller) handleConfiguration(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) (string, string, error) {
 	cmName := sb.Name + ConfigMap
+	secretName := sb.Name + Secret
+
+	// creating a configmap
+	desiredCm := newConfigMap(sb)
+	cm, err := c.configMapLister.ConfigMaps(sb.Namespace).Get(cmName)
+	err = c.handleResource(err, cm, sb, desiredCm)
+	if err != nil {
+		return "", "", err
+	}
+
+	// creating a secret for the credentials
+	creds, err := c.resolveCredentials(sb)
+	if err != nil {
+		return "", "", err
+	}
+	sbCopy.Status.GeneratedSecrets = creds.generated
+	c.setCredentialsCondition(sb, sbCopy, creds)
+
+	// referenced secrets might have been rotated, in that case the secret is updated
+	desiredSecret := newSecret(sb, creds)
+	secret, err := c.secretLister.Secrets(sb.Namespace).Get(secretName)
+	err = c.handleResource(err, secret, sb, desiredSecret)
+	if err != nil {
+		return "", "", err
+	}
+
+	// mongodb isn't deployed for an external database, its hash is used to run the connectivity check again when the uri or ca change
+	if externalDatabase(sb) != nil {
+		return configHash(desiredCm, desiredSecret), externalDatabaseHash(desiredSecret), nil
+	}
+
+	return configHash(desiredCm, desiredSecret), mongoConfigHash(desiredSecret), nil
+}
+
+// creating a pv, pvc, statefulset and services for MongoDB
+func (c *Controller) handleMongoDbDeployment(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, configHash string) error {
 	pvName := sb.Name + PersistentVolume
 	pvcName := sb.Name + PersistentVolumeClaim
-	depName := sb.Name + MongoDB
+	stsName := sb.Name + MongoDB
 	svcName := sb.Name + MongoDB
+	headlessSvcName := sb.Name + MongoDB + Headless
 	npName := sb.Name + MongoDB + NetworkPolicy
 
-	// creating a configmap
-	cm, err := c.configMapLister.ConfigMaps(sb.Namespace).Get(cmName)
-	err = c.handleResourceCreation(err, cm, sb, "", ConfigMap)
-	if err != nil {
+	if err := ValidateMongoImage(mongoImage(sb)); err != nil {
 		return err
 	}
 
-	// Creating a PV for mongoDB
-	pv, err := c.pvLister.Get(pvName)
-	err = c.handleResourceCreation(err, pv, sb, "", PersistentVolume)
+	// none of the resources of mongodb are created when an external database is used
+	if externalDatabase(sb) != nil {
+		return c.handleDatabaseCheck(sb, sbCopy, configHash)
+	}
+
+	storage := sb.Spec.Storage
+	claimName := c.mongoClaimName(sb)
+
+	// the claims are only provisioned from a snapshot when they are created by the statefulset
+	if claimName != "" && storage != nil && storage.SnapshotName != "" {
+		return fmt.Errorf("storage.snapshotName can't be used with the claim %s, it is only used for the claims of the statefulset", claimName)
+	}
+
+	// all the members of a replica set would use the same volume
+	if claimName != "" && mongoReplicas(sb) > 1 {
+		return fmt.Errorf("mongo.replicas can't be more than 1 when mongodb uses the claim %s, every member needs its own claim", claimName)
+	}
+
+	// Creating a static PV for mongoDB, only when it is explicitly requested
+	if storage != nil && storage.HostPath != "" && storage.ExistingClaim == "" {
+		if !c.clusterScoped {
+			return fmt.Errorf("storage.hostPath can't be used when only some namespaces are watched, the pv is cluster scoped")
+		}
+
+		pv, err := c.pvLister.Get(pvName)
+		err = c.handleResource(err, pv, sb, newPersistentVolume(sb))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Creating a PVC for mongoDB when the claims are not created from the volumeClaimTemplates
+	// an existing claim is only checked and not managed by the controller
+	if storage != nil && storage.ExistingClaim != "" {
+		if _, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(storage.ExistingClaim); err != nil {
+			return fmt.Errorf("existing claim %s: %w", storage.ExistingClaim, err)
+		}
+	} else if claimName != "" {
+		desiredPvc := newPersistentVolumeClaim(sb)
+		pvc, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(pvcName)
+
+		// the requested size of an existing claim is only changed if the volume can be expanded
+		if err == nil {
+			if err := c.resizeClaim(sb, sbCopy, pvc, desiredPvc); err != nil {
+				return err
+			}
+		}
+
+		err = c.handleResource(err, pvc, sb, desiredPvc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Creating the corresponding service
+	svc, err := c.serviceLister.Services(sb.Namespace).Get(svcName)
+	err = c.handleResource(err, svc, sb, newService(sb, MongoDB))
 	if err != nil {
 		return err
 	}
 
-	// Creating a PVC for mongoDB
-	pvc, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(pvcName)
-	err = c.handleResourceCreation(err, pvc, sb, "", PersistentVolumeClaim)
+	// Creating the headless service of the statefulset
+	headlessSvc, err := c.serviceLister.Services(sb.Namespace).Get(headlessSvcName)
+	err = c.handleResource(err, headlessSvc, sb, newMongoHeadlessService(sb))
 	if err != nil {
 		return err
 	}
 
-	// Creating mongoDB deployment
-	dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(depName)
-	err = c.handleResourceCreation(err, dep, sb, MongoDB, Deployment)
+	// Creating network policy for mongodb pods - Ingress rule
+	np, err := c.networkPolicyLister.NetworkPolicies(sb.Namespace).Get(npName)
+	err = c.handleResource(err, np, sb, newNetworkPolicy(sb, MongoDB))
 	if err != nil {
 		return err
 	}
 
-	// Creating the corresponding service
-	svc, err := c.serviceLister.Services(sb.Namespace).Get(svcName)
-	err = c.handleResourceCreation(err, svc, sb, MongoDB, Service)
+	// the statefulset is only created once the pods of the deployment used by older versions of the controller are gone
+	migrated, err := c.migrateMongoDeployment(sb)
+	if err != nil || !migrated {
+		return err
+	}
+
+	// Creating mongoDB statefulset
+	desiredSts := newMongoStatefulSet(sb, configHash, claimName)
+
+	// members are removed from the replica set before the statefulset is scaled down
+	if replicaSetMode(sb) && sb.Status.ReplicaSetMembers > *desiredSts.Spec.Replicas {
+		desiredSts.Spec.Replicas = &sb.Status.ReplicaSetMembers
+	}
+	sts, err := c.statefulSetLister.StatefulSets(sb.Namespace).Get(stsName)
+
+	// volumeClaimTemplates can't be changed, the claims are resized separately
+	if err == nil {
+		desiredSts.Spec.VolumeClaimTemplates = sts.Spec.VolumeClaimTemplates
+		c.recordImageChange(sb, "StatefulSet", stsName, &sts.Spec.Template.Spec, &desiredSts.Spec.Template.Spec)
+	}
+
+	err = c.handleResource(err, sts, sb, desiredSts)
 	if err != nil {
 		return err
 	}
 
-	// Creating network policy for mongodb pods - Ingress rule
-	np, err := c.networkPolicyLister.NetworkPolicies(sb.Namespace).Get(npName)
-	err = c.handleResourceCreation(err, np, sb, MongoDB, NetworkPolicy)
+	if claimName == "" {
+		if err = c.resizeTemplateClaims(sb, sbCopy); err != nil {
+			return err
+		}
+	}
+
+	return c.handleReplicaSet(sb, sbCopy)
+}
+
+// MongoDB used to run as a deployment (<name>-mongo) with a separate claim (<name>-pvc), the claim is kept and mounted by the statefulset
+// the deployment is deleted first and true is returned once its pods are gone, so that two pods never use the volume at the same time
+func (c *Controller) migrateMongoDeployment(sb *v1alpha1.SocialBook) (bool, error) {
+	depName := sb.Name + MongoDB
+
+	dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(depName)
+
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	if err == nil {
+		// a deployment with the same name which is not controlled by the SocialBook is left alone
+		if !metav1.IsControlledBy(dep, sb) {
+			return false, fmt.Errorf("%w: Deployment %s is controlled by another resource", errResourceExists, depName)
+		}
+
+		if dep.DeletionTimestamp == nil {
+			propagation := metav1.DeletePropagationForeground
+			err = c.clientset.AppsV1().Deployments(sb.Namespace).Delete(context.Background(), depName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+			if err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+
+			c.recorder.Eventf(sb, corev1.EventTypeNormal, "Migrating", "Deleted Deployment %s, MongoDB is moved to a StatefulSet", depName)
+		}
+
+		return false, nil
+	}
+
+	pods, err := c.podLister.Pods(sb.Namespace).List(labels.SelectorFromSet(labels.Set{"app": sb.Name + MongoDB}))
+	if err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods {
+		if owner := metav1.GetControllerOf(pod); owner == nil || owner.Kind != "StatefulSet" {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// applies the requested size to the claims created from the volumeClaimTemplates of the mongodb statefulset
+func (c *Controller) resizeTemplateClaims(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) error {
+	pvcs, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).List(labels.SelectorFromSet(labels.Set{"app": sb.Name + MongoDB}))
 	if err != nil {
 		return err
 	}
 
-	sbCopy.Status.MongoDB = Success
+	for _, pvc := range pvcs {
+		desired := newClaimResize(sb, pvc)
+
+		if err = c.resizeClaim(sb, sbCopy, pvc, desired); err != nil {
+			return err
+		}
+
+		if drifted, _ := hasDrifted(pvc, desired); !drifted {
+			continue
+		}
+
+		start := time.Now()
+		err = c.patchClaimSize(sb, desired)
+		observeResource("PersistentVolumeClaim", start, err)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // creating deployment and service for socialbook(image: ashwin901/social-book-server)
-func (c *Controller) handleSocialBookDeployment(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) error {
+// the deployment is scaled down to 0 replicas when scaleDown is set
+func (c *Controller) handleSocialBookDeployment(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, configHash string, scaleDown bool) error {
 
 	svcName := sb.Name
 	npName := sb.Name + NetworkPolicy
 
-	// Creating a deployment for image: ashwin901/social-book-server
+	// Creating a deployment for image: ashwin901/social-book-server (or the image of the spec)
 	dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(sb.Name)
-	err = c.handleResourceCreation(err, dep, sb, SocialBook, Deployment)
-	if err != nil {
-		return err
+	desiredDep := newSocialBookDeployment(sb, configHash)
+	if scaleDown {
+		var replicas int32
+		desiredDep.Spec.Replicas = &replicas
 	}
 
-	// checking if the replicas of the deployment are same as the desired number of replicas
-	if sb.Spec.Replicas != *(dep.Spec.Replicas) {
-		fmt.Println("Incorrect number of replicas")
-		dep, err = c.clientset.AppsV1().Deployments(sb.Namespace).Update(context.Background(), newDeployment(sb, SocialBook), metav1.UpdateOptions{})
+	if err == nil {
+		c.recordImageChange(sb, "Deployment", sb.Name, &dep.Spec.Template.Spec, &desiredDep.Spec.Template.Spec)
+	}
 
-		if err != nil {
-			fmt.Println("Error while updating deployment")
-			return err
-		}
+	err = c.handleResource(err, dep, sb, desiredDep)
+	if err != nil {
+		return err
 	}
 
 	// Creating the corresponding service(external)
 	svc, err := c.serviceLister.Services(sb.Namespace).Get(svcName)
-	err = c.handleResourceCreation(err, svc, sb, SocialBook, Service)
+	err = c.handleResource(err, svc, sb, newService(sb, SocialBook))
 	if err != nil {
 		return err
 	}
 
 	// Creating network policy for socialbook pods - Egress rule
 	np, err := c.networkPolicyLister.NetworkPolicies(sb.Namespace).Get(npName)
-	err = c.handleResourceCreation(err, np, sb, SocialBook, NetworkPolicy)
+	err = c.handleResource(err, np, sb, newNetworkPolicy(sb, SocialBook))
 	if err != nil {
 		return err
 	}
 
-	sbCopy.Status.SocialBook = Success
 	return nil
 }
 
-func (c *Controller) handleResourceCreation(err error, resource interface{}, sb *v1alpha1.SocialBook, appType string, resourceName string) error {
+// applies the desired resource if it is not present or if it has drifted from the desired state
+func (c *Controller) handleResource(err error, resource interface{}, sb *v1alpha1.SocialBook, desired interface{}) (result error) {
+	kind := reflect.TypeOf(desired).Elem().Name()
+	name := desired.(metav1.Object).GetName()
+
+	start := time.Now()
+	defer func() {
+		observeResource(kind, start, result)
+	}()
+
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
 	if errors.IsNotFound(err) {
-		switch resourceName {
-		case ConfigMap:
-			resource, err = c.clientset.CoreV1().ConfigMaps(sb.Namespace).Create(context.Background(), newConfigMap(sb), metav1.CreateOptions{})
-			break
-		case PersistentVolume:
-			resource, err = c.clientset.CoreV1().PersistentVolumes().Create(context.Background(), newPersistentVolume(sb), metav1.CreateOptions{})
-			break
-		case PersistentVolumeClaim:
-			resource, err = c.clientset.CoreV1().PersistentVolumeClaims(sb.Namespace).Create(context.Background(), newPersistentVolumeClaim(sb), metav1.CreateOptions{})
-			break
-		case Service:
-			resource, err = c.clientset.CoreV1().Services(sb.Namespace).Create(context.Background(), newService(sb, appType), metav1.CreateOptions{})
-			break
-		case Deployment:
-			resource, err = c.clientset.AppsV1().Deployments(sb.Namespace).Create(context.Background(), newDeployment(sb, appType), metav1.CreateOptions{})
-			break
-		case NetworkPolicy:
-			resource, err = c.clientset.NetworkingV1().NetworkPolicies(sb.Namespace).Create(context.Background(), newNetworkPolicy(sb, appType), metav1.CreateOptions{})
-			break
-		default:
-			err = fmt.Errorf("Unkown resource %s", resourceName)
-			break
+		if err = c.applyResource(sb, desired); err != nil {
+			return err
 		}
+
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "Created", "Created %s %s", kind, name)
+		return nil
+	}
+
+	object := resource.(metav1.Object)
+
+	// resources without a controller (for example created manually before the SocialBook) are adopted
+	if owner := metav1.GetControllerOf(object); owner == nil {
+		if err = c.applyResource(sb, desired); err != nil {
+			return err
+		}
+
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "Adopted", "Adopted existing %s %s", kind, name)
+		return nil
+	}
+
+	// check if the resource is controlled by current SocialBook resource
+	if !metav1.IsControlledBy(object, sb) {
+		return fmt.Errorf("%w: %s %s is controlled by another resource", errResourceExists, kind, name)
+	}
+
+	drifted, err := hasDrifted(resource, desired)
+	if err != nil || !drifted {
+		return err
 	}
 
+	log.Printf("%s %s has drifted from the desired state, applying it again", kind, name)
+
+	// only the fields of the controller are applied again, the fields added by other managers are kept
+	if managers := foreignManagers(resource); len(managers) > 0 {
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "ForeignFields", "Keeping the fields of %s %s set by %s", kind, name, strings.Join(managers, ", "))
+	}
+
+	// resources created before server side apply was used are owned by an update manager
+	if err = c.upgradeManagedFields(sb, resource); err != nil {
+		return err
+	}
+
+	if err = c.applyResource(sb, desired); err != nil {
+		return err
+	}
+
+	c.recorder.Eventf(sb, corev1.EventTypeNormal, "Updated", "Updated %s %s to the desired state", kind, name)
+	return nil
+}
+
+// server side apply of the desired resource, fields owned by other managers are not overwritten and a conflict is returned instead
+func (c *Controller) applyResource(sb *v1alpha1.SocialBook, desired interface{}) error {
+	data, err := json.Marshal(desired)
 	if err != nil {
 		return err
 	}
 
-	// check if the resource is controlled by current SocialBook resource
-	if !metav1.IsControlledBy(resource.(metav1.Object), sb) {
-		return fmt.Errorf("%s", "Resource already exists")
+	name := desired.(metav1.Object).GetName()
+	options := metav1.PatchOptions{FieldManager: FieldManager}
+
+	switch desired.(type) {
+	case *corev1.ConfigMap:
+		_, err = c.clientset.CoreV1().ConfigMaps(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *corev1.Secret:
+		_, err = c.clientset.CoreV1().Secrets(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *corev1.PersistentVolume:
+		_, err = c.clientset.CoreV1().PersistentVolumes().Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *corev1.PersistentVolumeClaim:
+		_, err = c.clientset.CoreV1().PersistentVolumeClaims(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *corev1.Service:
+		_, err = c.clientset.CoreV1().Services(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *appsv1.Deployment:
+		_, err = c.clientset.AppsV1().Deployments(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *appsv1.StatefulSet:
+		_, err = c.clientset.AppsV1().StatefulSets(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *networkingv1.NetworkPolicy:
+		_, err = c.clientset.NetworkingV1().NetworkPolicies(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *batchv1.Job:
+		_, err = c.clientset.BatchV1().Jobs(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	case *batchv1.CronJob:
+		_, err = c.clientset.BatchV1().CronJobs(sb.Namespace).Patch(context.Background(), name, types.ApplyPatchType, data, options)
+	default:
+		err = fmt.Errorf("Unkown resource %T", desired)
+	}
+
+	if errors.IsConflict(err) {
+		return fmt.Errorf("conflict while applying %s %s, fields are managed by another actor: %w", reflect.TypeOf(desired).Elem().Name(), name, err)
+	}
+
+	return err
+}
+
+// moves the fields owned by the update manager of older versions of the controller to the apply manager
+func (c *Controller) upgradeManagedFields(sb *v1alpha1.SocialBook, resource interface{}) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(resource.(runtime.Object), sets.New(FieldManager), FieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+
+	name := resource.(metav1.Object).GetName()
+
+	switch resource.(type) {
+	case *corev1.ConfigMap:
+		_, err = c.clientset.CoreV1().ConfigMaps(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *corev1.Secret:
+		_, err = c.clientset.CoreV1().Secrets(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *corev1.PersistentVolume:
+		_, err = c.clientset.CoreV1().PersistentVolumes().Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *corev1.PersistentVolumeClaim:
+		_, err = c.clientset.CoreV1().PersistentVolumeClaims(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *corev1.Service:
+		_, err = c.clientset.CoreV1().Services(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *appsv1.Deployment:
+		_, err = c.clientset.AppsV1().Deployments(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *appsv1.StatefulSet:
+		_, err = c.clientset.AppsV1().StatefulSets(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *networkingv1.NetworkPolicy:
+		_, err = c.clientset.NetworkingV1().NetworkPolicies(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *batchv1.Job:
+		_, err = c.clientset.BatchV1().Jobs(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	case *batchv1.CronJob:
+		_, err = c.clientset.BatchV1().CronJobs(sb.Namespace).Patch(context.Background(), name, types.JSONPatchType, patch, metav1.PatchOptions{})
 	}
 
 	return err
 }
 
 // updating the status of SocialBook custom resource
-func (c *Controller) updateSocialbookStatus(sbCopy *v1alpha1.SocialBook) {
+func (c *Controller) updateSocialbookStatus(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) {
+	// no need to update if nothing has changed
+	if equality.Semantic.DeepEqual(sb.Status, sbCopy.Status) {
+		return
+	}
+
 	_, err := c.customClientset.OperatorsV1alpha1().SocialBooks(sbCopy.Namespace).UpdateStatus(context.Background(), sbCopy, metav1.UpdateOptions{})
 
 	if err != nil {
 		log.Printf("Error %s while updating status of %s", err.Error(), sbCopy.Name)
+		c.recorder.Eventf(sb, corev1.EventTypeWarning, "StatusUpdateFailed", "Error while updating status: %s", err.Error())
+		return
 	}
 
 	log.Printf("Status for %s successfully updated", sbCopy.Name)
@@ -397,6 +951,10 @@ func (c *Controller) addItemToQueue(obj interface{}) {
 
 // checks if the resource is owned by "SocialBook" kind
 func (c *Controller) handleObject(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
 	var object metav1.Object
 	var ok bool
 	if object, ok = obj.(metav1.Object); !ok {
@@ -405,14 +963,35 @@ func (c *Controller) handleObject(obj interface{}) {
 	}
 
 	if owner := metav1.GetControllerOf(object); owner != nil {
+		name := owner.Name
+
+		// objects created by other controllers for a SocialBook (jobs of the backup cronjob) carry its name as a label
 		if owner.Kind != Kind {
-			return
+			if name, ok = object.GetLabels()[SocialBookLabel]; !ok {
+				return
+			}
 		}
 
-		sb, err := c.socialbookLister.SocialBooks(object.GetNamespace()).Get(owner.Name)
+		// cluster scoped resources (pv) carry the namespace of the SocialBook as a label
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = object.GetLabels()[NamespaceLabel]
+		}
+
+		sb, err := c.socialbookLister.SocialBooks(namespace).Get(name)
+
+		// owned objects of SocialBooks in namespaces which are not watched (pvs) or which belong to another shard are seen as well
+		if errors.IsNotFound(err) {
+			return
+		}
 
 		if err != nil {
-			log.Printf("Error %s while getting socialbook %s", err.Error(), owner.Name)
+			log.Printf("Error %s while getting socialbook %s", err.Error(), name)
+			return
+		}
+
+		// the labels of the SocialBook might have changed and the informer has not caught up yet
+		if !c.selector.Matches(labels.Set(sb.Labels)) {
 			return
 		}
 
@@ -420,6 +999,44 @@ func (c *Controller) handleObject(obj interface{}) {
 	}
 }
 
+// reason of the warning event recorded when a reconcile fails
+func eventReason(err error) string {
+	switch {
+	case stderrors.Is(err, errResourceExists):
+		return "ResourceExists"
+	case errors.IsConflict(err):
+		return "Conflict"
+	}
+	return "ReconcileFailed"
+}
+
+// enqueues the SocialBooks which reference the secret in their spec
+func (c *Controller) handleReferencedSecret(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	var object metav1.Object
+	var ok bool
+	if object, ok = obj.(metav1.Object); !ok {
+		log.Printf("Invalid object %s", obj)
+		return
+	}
+
+	sbs, err := c.socialbookLister.SocialBooks(object.GetNamespace()).List(labels.Everything())
+
+	if err != nil {
+		log.Printf("Error %s while listing socialbooks in %s", err.Error(), object.GetNamespace())
+		return
+	}
+
+	for _, sb := range sbs {
+		if referencesSecret(sb, object.GetName()) {
+			c.addItemToQueue(sb)
+		}
+	}
+}
+
 // used to set the owner reference of resources
 func setOwnerReference(sb *v1alpha1.SocialBook) []metav1.OwnerReference {
 	return []metav1.OwnerReference{
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	customfake "github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned/fake"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	appsLister "k8s.io/client-go/listers/apps/v1"
+	batchLister "k8s.io/client-go/listers/batch/v1"
+	coreLister "k8s.io/client-go/listers/core/v1"
+	networkingLister "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// SocialBook sb in the dev namespace used by the tests
+func newTestSocialBook() *v1alpha1.SocialBook {
+	return &v1alpha1.SocialBook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sb",
+			Namespace: "dev",
+			UID:       "sb-uid",
+		},
+	}
+}
+
+func newTestIndexer(t *testing.T, objects ...interface{}) cache.Indexer {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		if err := indexer.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return indexer
+}
+
+// controller with fake clients, the objects are added to the clients and to the listers of their type
+// (unstructured objects are VolumeSnapshots and only added to the dynamic client)
+func newTestController(t *testing.T, objects ...runtime.Object) *Controller {
+	t.Helper()
+
+	var typed, snapshots []runtime.Object
+	indexers := map[string][]interface{}{}
+
+	for _, obj := range objects {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			snapshots = append(snapshots, obj)
+			continue
+		}
+
+		typed = append(typed, obj)
+		kind := fmt.Sprintf("%T", obj)
+		indexers[kind] = append(indexers[kind], obj)
+	}
+
+	indexer := func(obj interface{}) cache.Indexer {
+		return newTestIndexer(t, indexers[fmt.Sprintf("%T", obj)]...)
+	}
+
+	listKinds := map[schema.GroupVersionResource]string{volumeSnapshotResource: "VolumeSnapshotList"}
+
+	return &Controller{
+		clientset:           fake.NewSimpleClientset(typed...),
+		customClientset:     customfake.NewSimpleClientset(),
+		dynamicClient:       fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, snapshots...),
+		deploymentLister:    appsLister.NewDeploymentLister(indexer(&appsv1.Deployment{})),
+		statefulSetLister:   appsLister.NewStatefulSetLister(indexer(&appsv1.StatefulSet{})),
+		serviceLister:       coreLister.NewServiceLister(indexer(&corev1.Service{})),
+		configMapLister:     coreLister.NewConfigMapLister(indexer(&corev1.ConfigMap{})),
+		secretLister:        coreLister.NewSecretLister(indexer(&corev1.Secret{})),
+		pvLister:            coreLister.NewPersistentVolumeLister(indexer(&corev1.PersistentVolume{})),
+		pvcLister:           coreLister.NewPersistentVolumeClaimLister(indexer(&corev1.PersistentVolumeClaim{})),
+		networkPolicyLister: networkingLister.NewNetworkPolicyLister(indexer(&networkingv1.NetworkPolicy{})),
+		podLister:           coreLister.NewPodLister(indexer(&corev1.Pod{})),
+		jobLister:           batchLister.NewJobLister(indexer(&batchv1.Job{})),
+		cronJobLister:       batchLister.NewCronJobLister(indexer(&batchv1.CronJob{})),
+		recorder:            record.NewFakeRecorder(10),
+		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+	}
+}
+
+// job of the backup cronjob of the SocialBook, failed when it hasn't succeeded
+func newTestBackupJob(sb *v1alpha1.SocialBook, name string, created time.Time, succeeded bool) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         sb.Namespace,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{SocialBookLabel: sb.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: sb.Name + Backup}}, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+	}
+
+	if succeeded {
+		completed := metav1.NewTime(created.Add(time.Minute))
+		job.Status.CompletionTime = &completed
+		job.Status.Succeeded = 1
+	} else {
+		job.Status.Failed = 1
+	}
+
+	return job
+}
+
+func TestEventReason(t *testing.T) {
+	conflict := errors.NewConflict(schema.GroupResource{Resource: "deployments"}, "sb", stderrors.New("replicas are managed by kubectl"))
+
+	cases := map[string]error{
+		"Conflict":        fmt.Errorf("conflict while applying Deployment sb, fields are managed by another actor: %w", conflict),
+		"ResourceExists":  fmt.Errorf("%w: Deployment sb is controlled by another resource", errResourceExists),
+		"ReconcileFailed": stderrors.New("connection refused"),
+	}
+
+	for reason, err := range cases {
+		if got := eventReason(err); got != reason {
+			t.Errorf("expected reason %s for %q, got %s", reason, err, got)
+		}
+	}
+}
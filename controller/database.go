@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// pings the database, the output of a failed check is reported in the status
+const databaseCheckScript = `mongosh "$MONGODB_URI" --quiet --eval 'db.runCommand({ ping: 1 })'`
+
+// the external database of the SocialBook, nil when mongodb is deployed by the controller
+func externalDatabase(sb *v1alpha1.SocialBook) *v1alpha1.ExternalDatabaseSpec {
+	if sb.Spec.Database == nil {
+		return nil
+	}
+
+	return sb.Spec.Database.External
+}
+
+func externalDatabasePort(external *v1alpha1.ExternalDatabaseSpec) int {
+	if external.Port > 0 {
+		return int(external.Port)
+	}
+
+	return 27017
+}
+
+// enables tls with the ca mounted at DatabaseCAPath, the options are added to the query of the uri
+func withDatabaseCA(uri string) string {
+	options := "tls=true&tlsCAFile=" + DatabaseCAPath
+
+	if strings.Contains(uri, "?") {
+		return uri + "&" + options
+	}
+
+	// the options have to follow the path of the uri, which is empty in an uri like mongodb://host:27017
+	_, hosts, _ := strings.Cut(uri, "://")
+	if !strings.Contains(hosts, "/") {
+		return uri + "/?" + options
+	}
+
+	return uri + "?" + options
+}
+
+// mounts the ca of the external database in all the containers of the pod, nothing is mounted when tls isn't used
+func mountDatabaseCA(sb *v1alpha1.SocialBook, podSpec *corev1.PodSpec) {
+	external := externalDatabase(sb)
+	if external == nil || external.CASecretRef == nil {
+		return
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "mongodb-ca",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: sb.Name + Secret,
+				Items: []corev1.KeyToPath{
+					{
+						Key:  "mongodb-ca",
+						Path: "mongodb-ca.pem",
+					},
+				},
+			},
+		},
+	})
+
+	mount := corev1.VolumeMount{
+		Name:      "mongodb-ca",
+		MountPath: "/etc/socialbook",
+		ReadOnly:  true,
+	}
+
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].VolumeMounts = append(podSpec.InitContainers[i].VolumeMounts, mount)
+	}
+
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, mount)
+	}
+}
+
+// job connecting to the external database, configHash is added as an annotation so that the check is run again when the uri or ca change
+func newDatabaseCheckJob(sb *v1alpha1.SocialBook, configHash string) *batchv1.Job {
+	var backoffLimit int32
+	var deadline int64
+	deadline = 120
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Containers: []corev1.Container{
+			{
+				Name:                     "check",
+				Image:                    mongoImage(sb),
+				Command:                  []string{"sh", "-c", databaseCheckScript},
+				TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				Env: []corev1.EnvVar{
+					{
+						Name: "MONGODB_URI",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: sb.Name + Secret,
+								},
+								Key: "mongodb-uri",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	mountDatabaseCA(sb, &podSpec)
+	setImagePullOptions(sb, &podSpec)
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            sb.Name + MongoDB + DatabaseCheck,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+			Annotations: map[string]string{
+				ConfigHashAnnotation: configHash,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": sb.Name + MongoDB + DatabaseCheck,
+					},
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	return job
+}
+
+// runs the connectivity check of the external database every DatabaseCheckInterval (and whenever its uri or ca change) and reports
+// the result of the last check in status.database, configHash is the hash of the uri and ca
+func (c *Controller) handleDatabaseCheck(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, configHash string) error {
+	if !externalDatabase(sb).ConnectivityCheck {
+		sbCopy.Status.Database = nil
+		return nil
+	}
+
+	if sbCopy.Status.Database == nil {
+		sbCopy.Status.Database = &v1alpha1.DatabaseStatus{}
+	}
+
+	jobName := sb.Name + MongoDB + DatabaseCheck
+	job, err := c.jobLister.Jobs(sb.Namespace).Get(jobName)
+
+	// the job isn't updated through handleResource so that no event is recorded for every check
+	if errors.IsNotFound(err) {
+		return c.applyResource(sb, newDatabaseCheckJob(sb, configHash))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(job, sb) {
+		return fmt.Errorf("%w: Job %s is controlled by another resource", errResourceExists, jobName)
+	}
+
+	// the uri or ca have changed, the result of the check is outdated
+	if job.Annotations[ConfigHashAnnotation] != configHash {
+		sbCopy.Status.Database.LastCheckTime = nil
+		return c.deleteDatabaseCheck(sb, jobName)
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue || (condition.Type != batchv1.JobComplete && condition.Type != batchv1.JobFailed) {
+			continue
+		}
+
+		finished := condition.LastTransitionTime
+		status := sbCopy.Status.Database
+		status.Reachable = condition.Type == batchv1.JobComplete
+		status.Message = ""
+
+		if !status.Reachable {
+			status.Message = c.databaseCheckOutput(sb, jobName)
+			if status.Message == "" {
+				status.Message = condition.Message
+			}
+		}
+
+		if status.LastCheckTime == nil || !status.LastCheckTime.Equal(&finished) {
+			status.LastCheckTime = &finished
+
+			if !status.Reachable {
+				c.recorder.Eventf(sb, corev1.EventTypeWarning, "DatabaseUnreachable", "External database can't be reached: %s", status.Message)
+			}
+		}
+
+		// the job is run again once the interval has passed
+		next := finished.Add(DatabaseCheckInterval)
+		if time.Now().Before(next) {
+			c.queue.AddAfter(sb.Namespace+"/"+sb.Name, time.Until(next))
+			return nil
+		}
+
+		return c.deleteDatabaseCheck(sb, jobName)
+	}
+
+	return nil
+}
+
+func (c *Controller) deleteDatabaseCheck(sb *v1alpha1.SocialBook, jobName string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.clientset.BatchV1().Jobs(sb.Namespace).Delete(context.Background(), jobName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// termination message (the last lines of the logs) of the check
+func (c *Controller) databaseCheckOutput(sb *v1alpha1.SocialBook, jobName string) string {
+	pods, err := c.podLister.Pods(sb.Namespace).List(labels.SelectorFromSet(labels.Set{"job-name": jobName}))
+	if err != nil {
+		return ""
+	}
+
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated != nil && status.State.Terminated.Message != "" {
+				return strings.TrimSpace(status.State.Terminated.Message)
+			}
+		}
+	}
+
+	return ""
+}
+
+// state of the external database used as the mongodb component of the status
+func externalDatabaseState(status *v1alpha1.DatabaseStatus) componentState {
+	switch {
+	case status == nil:
+		return componentState{ready: true, reason: "ExternalDatabase", message: "connectivity is not checked"}
+	case status.LastCheckTime == nil:
+		return componentState{reason: "DatabaseCheckPending", message: "connectivity check is running"}
+	case status.Reachable:
+		return componentState{ready: true, reason: "DatabaseReachable", message: "connectivity check succeeded"}
+	default:
+		return componentState{failed: true, reason: "DatabaseUnreachable", message: status.Message}
+	}
+}
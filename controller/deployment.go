@@ -7,110 +7,22 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-func newDeployment(sb *v1alpha1.SocialBook, appType string) *appsv1.Deployment {
-	if appType == MongoDB {
-		return newMongoDeployment(sb)
-	}
-
-	return newSocialBookDeployment(sb)
-}
-
-func newMongoDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
-
-	var replicas int32
-	replicas = 1
-
-	depName := sb.Name + MongoDB
-	cmName := sb.Name + ConfigMap
-
-	// mongo db deployment
-	dep := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            depName,
-			Namespace:       sb.Namespace,
-			OwnerReferences: setOwnerReference(sb),
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": sb.Name + MongoDB,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: sb.Name + MongoDB,
-					Labels: map[string]string{
-						"app": sb.Name + MongoDB,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Volumes: []corev1.Volume{
-						{
-							Name: sb.Name + PersistentVolume,
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: sb.Name + PersistentVolumeClaim,
-								},
-							},
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:  sb.Name + MongoDB,
-							Image: "mongo",
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: 27017,
-								},
-							},
-							Env: []corev1.EnvVar{
-								{
-									Name: "MONGO_INITDB_ROOT_USERNAME",
-									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
-											},
-											Key: "mongo-root-username",
-										},
-									},
-								},
-								{
-									Name: "MONGO_INITDB_ROOT_PASSWORD",
-									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
-											},
-											Key: "mongo-root-password",
-										},
-									},
-								},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      sb.Name + PersistentVolume,
-									MountPath: "/data/db",
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return dep
-}
-
-func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
+// configHash is added as an annotation to the pod template, so that the pods are rolled when the configuration changes
+func newSocialBookDeployment(sb *v1alpha1.SocialBook, configHash string) *appsv1.Deployment {
 	portNumber, _ := strconv.Atoi(sb.Spec.Port)
 	cmName := sb.Name + ConfigMap
+	secretName := sb.Name + Secret
+	maxUnavailable := intstr.FromInt(0)
+	maxSurge := intstr.FromInt(1)
 
 	dep := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            sb.Name,
 			Namespace:       sb.Namespace,
@@ -118,6 +30,14 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &sb.Spec.Replicas,
+			// a new pod is started and ready before an old one is removed, so the app stays available when the image changes
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
+			},
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": sb.Name + SocialBook,
@@ -129,12 +49,15 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 					Labels: map[string]string{
 						"app": sb.Name + SocialBook,
 					},
+					Annotations: map[string]string{
+						ConfigHashAnnotation: configHash,
+					},
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
 							Name:  sb.Name,
-							Image: Image,
+							Image: appImage(sb),
 							Ports: []corev1.ContainerPort{
 								{
 									ContainerPort: int32(portNumber),
@@ -155,9 +78,9 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 								{
 									Name: "MONGODB_URI",
 									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+										SecretKeyRef: &corev1.SecretKeySelector{
 											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
+												Name: secretName,
 											},
 											Key: "mongodb-uri",
 										},
@@ -166,9 +89,9 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 								{
 									Name: "SECRET",
 									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+										SecretKeyRef: &corev1.SecretKeySelector{
 											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
+												Name: secretName,
 											},
 											Key: "secret",
 										},
@@ -177,9 +100,9 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 								{
 									Name: "STRIPE_API_KEY",
 									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+										SecretKeyRef: &corev1.SecretKeySelector{
 											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
+												Name: secretName,
 											},
 											Key: "stripe-api-key",
 										},
@@ -199,9 +122,9 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 								{
 									Name: "USER_PASSWORD",
 									ValueFrom: &corev1.EnvVarSource{
-										ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+										SecretKeyRef: &corev1.SecretKeySelector{
 											LocalObjectReference: corev1.LocalObjectReference{
-												Name: cmName,
+												Name: secretName,
 											},
 											Key: "user-pwd",
 										},
@@ -226,5 +149,8 @@ func newSocialBookDeployment(sb *v1alpha1.SocialBook) *appsv1.Deployment {
 		},
 	}
 
+	mountDatabaseCA(sb, &dep.Spec.Template.Spec)
+	setImagePullOptions(sb, &dep.Spec.Template.Spec)
+
 	return dep
 }
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	batchv1ac "k8s.io/client-go/applyconfigurations/batch/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	networkingv1ac "k8s.io/client-go/applyconfigurations/networking/v1"
+)
+
+// a resource has drifted when the fields owned by the controller differ from the desired ones (a field was changed and taken
+// over by another manager) or when it owns fields which aren't desired anymore, fields of other managers are not compared
+// the owned values are compared with DeepDerivative as atomic fields (like the ports of a network policy) are owned with
+// the defaults filled in by the api server
+func hasDrifted(resource interface{}, desired interface{}) (bool, error) {
+	switch obj := desired.(type) {
+	case *corev1.PersistentVolume:
+		// the volume source of a pv can't be changed
+		pv := resource.(*corev1.PersistentVolume)
+		return !equality.Semantic.DeepEqual(obj.Spec.Capacity, pv.Spec.Capacity) || obj.Spec.PersistentVolumeReclaimPolicy != pv.Spec.PersistentVolumeReclaimPolicy, nil
+	case *corev1.PersistentVolumeClaim:
+		// only the requested storage of a pvc can be changed
+		return !equality.Semantic.DeepDerivative(obj.Spec.Resources, resource.(*corev1.PersistentVolumeClaim).Spec.Resources), nil
+	case *batchv1.Job:
+		// the template of a job can't be changed
+		return false, nil
+	}
+
+	undesired, err := ownsUndesiredFields(resource, desired)
+	if err != nil || undesired {
+		return undesired, err
+	}
+
+	owned, err := ownedFields(resource)
+	if err != nil {
+		return false, err
+	}
+
+	switch obj := desired.(type) {
+	case *corev1.ConfigMap:
+		return !equality.Semantic.DeepDerivative(obj.Data, owned.(*corev1.ConfigMap).Data), nil
+	case *corev1.Secret:
+		return !equality.Semantic.DeepDerivative(obj.Data, owned.(*corev1.Secret).Data), nil
+	case *corev1.Service:
+		return !equality.Semantic.DeepDerivative(obj.Spec, owned.(*corev1.Service).Spec), nil
+	case *appsv1.Deployment:
+		return !equality.Semantic.DeepDerivative(obj.Spec, owned.(*appsv1.Deployment).Spec), nil
+	case *appsv1.StatefulSet:
+		// volumeClaimTemplates can't be changed, the desired ones are copied from the statefulset
+		desiredSpec := obj.Spec.DeepCopy()
+		desiredSpec.VolumeClaimTemplates = nil
+		return !equality.Semantic.DeepDerivative(*desiredSpec, owned.(*appsv1.StatefulSet).Spec), nil
+	case *networkingv1.NetworkPolicy:
+		return !equality.Semantic.DeepDerivative(obj.Spec, owned.(*networkingv1.NetworkPolicy).Spec), nil
+	case *batchv1.CronJob:
+		return !equality.Semantic.DeepDerivative(obj.Spec, owned.(*batchv1.CronJob).Spec), nil
+	}
+
+	return false, nil
+}
+
+// checks if the apply manager of the controller owns fields which are not set in the desired resource anymore (like a
+// removed env var or pull secret), they are removed from the resource by the next apply
+func ownsUndesiredFields(resource interface{}, desired interface{}) (bool, error) {
+	for _, entry := range resource.(metav1.Object).GetManagedFields() {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return false, err
+		}
+
+		data, err := json.Marshal(desired)
+		if err != nil {
+			return false, err
+		}
+
+		var value interface{}
+		if err = json.Unmarshal(data, &value); err != nil {
+			return false, err
+		}
+
+		return !containsFields(fields, value), nil
+	}
+
+	return false, nil
+}
+
+// checks if all the fields of the field set (in the FieldsV1 format) are set in the value decoded from json
+// f:<name> is a field of an object, k:<key> the item of a list with the key fields (key fields which are not set in
+// the value are defaulted by the api server and match any value), v:<value> an item of a set and i:<index> an item of a list
+func containsFields(fields map[string]interface{}, value interface{}) bool {
+	for path, children := range fields {
+		childFields, _ := children.(map[string]interface{})
+		var child interface{}
+		var found bool
+
+		switch {
+		case path == ".":
+			continue
+		case strings.HasPrefix(path, "f:"):
+			object, _ := value.(map[string]interface{})
+			child, found = object[strings.TrimPrefix(path, "f:")]
+		case strings.HasPrefix(path, "k:"):
+			key := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(path, "k:")), &key); err != nil {
+				return false
+			}
+			child, found = listItemWithKey(value, key)
+		case strings.HasPrefix(path, "v:"):
+			var item interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(path, "v:")), &item); err != nil {
+				return false
+			}
+			items, _ := value.([]interface{})
+			for _, candidate := range items {
+				if reflect.DeepEqual(candidate, item) {
+					child, found = candidate, true
+				}
+			}
+		case strings.HasPrefix(path, "i:"):
+			index, err := strconv.Atoi(strings.TrimPrefix(path, "i:"))
+			items, _ := value.([]interface{})
+			if err == nil && index >= 0 && index < len(items) {
+				child, found = items[index], true
+			}
+		}
+
+		if !found || !containsFields(childFields, child) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func listItemWithKey(value interface{}, key map[string]interface{}) (interface{}, bool) {
+	items, _ := value.([]interface{})
+
+	for _, item := range items {
+		object, _ := item.(map[string]interface{})
+		matches, set := true, 0
+
+		for name, keyValue := range key {
+			if itemValue, ok := object[name]; ok {
+				matches = matches && reflect.DeepEqual(itemValue, keyValue)
+				set++
+			}
+		}
+
+		if matches && set > 0 {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+// fields of the resource owned by the apply manager of the controller, returned as the type of the resource
+func ownedFields(resource interface{}) (interface{}, error) {
+	var extracted interface{}
+	var owned interface{}
+	var err error
+
+	switch obj := resource.(type) {
+	case *corev1.ConfigMap:
+		extracted, err = corev1ac.ExtractConfigMap(obj, FieldManager)
+		owned = &corev1.ConfigMap{}
+	case *corev1.Secret:
+		extracted, err = corev1ac.ExtractSecret(obj, FieldManager)
+		owned = &corev1.Secret{}
+	case *corev1.Service:
+		extracted, err = corev1ac.ExtractService(obj, FieldManager)
+		owned = &corev1.Service{}
+	case *appsv1.Deployment:
+		extracted, err = appsv1ac.ExtractDeployment(obj, FieldManager)
+		owned = &appsv1.Deployment{}
+	case *appsv1.StatefulSet:
+		extracted, err = appsv1ac.ExtractStatefulSet(obj, FieldManager)
+		owned = &appsv1.StatefulSet{}
+	case *networkingv1.NetworkPolicy:
+		extracted, err = networkingv1ac.ExtractNetworkPolicy(obj, FieldManager)
+		owned = &networkingv1.NetworkPolicy{}
+	case *batchv1.CronJob:
+		extracted, err = batchv1ac.ExtractCronJob(obj, FieldManager)
+		owned = &batchv1.CronJob{}
+	default:
+		return nil, fmt.Errorf("Unkown resource %T", resource)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(extracted)
+	if err != nil {
+		return nil, err
+	}
+
+	return owned, json.Unmarshal(data, owned)
+}
+
+// managers other than the controller owning fields of the spec (the data of configmaps and secrets), for example kubectl edit
+// adding an env var or a key, kubectl rollout restart annotating the pod template or a webhook injecting a sidecar, their
+// fields are kept, fields changed through a subresource (the replicas set through the scale subresource) are left out
+func foreignManagers(resource interface{}) []string {
+	var managers []string
+
+	for _, entry := range resource.(metav1.Object).GetManagedFields() {
+		if entry.Manager == FieldManager || entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+
+		for _, field := range []string{"f:spec", "f:data", "f:binaryData"} {
+			if _, ok := fields[field]; ok {
+				managers = append(managers, entry.Manager)
+				break
+			}
+		}
+	}
+
+	return managers
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func managedFields(manager string, operation metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func newDriftTestService(targetPorts ...int) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sb",
+			Namespace: "dev",
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "sb-sb"},
+		},
+	}
+
+	for i, targetPort := range targetPorts {
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+			Port:       int32(80 + i),
+			TargetPort: intstr.FromInt(targetPort),
+		})
+	}
+
+	return svc
+}
+
+// the service as stored by the api server: defaults and the allocated cluster ip are filled in
+func newDriftTestLiveService(fields ...metav1.ManagedFieldsEntry) *corev1.Service {
+	svc := newDriftTestService(3000, 3001)
+	svc.ManagedFields = fields
+	svc.Spec.ClusterIP = "10.0.0.1"
+	svc.Spec.Type = corev1.ServiceTypeClusterIP
+	for i := range svc.Spec.Ports {
+		svc.Spec.Ports[i].Protocol = corev1.ProtocolTCP
+	}
+
+	return svc
+}
+
+const driftTestServiceFields = `{"f:spec":{"f:selector":{"f:app":{}},"f:ports":{` +
+	`"k:{\"port\":80,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:targetPort":{}},` +
+	`"k:{\"port\":81,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:targetPort":{}}}}}`
+
+func TestHasDrifted(t *testing.T) {
+	cases := []struct {
+		name     string
+		resource interface{}
+		desired  interface{}
+		drifted  bool
+	}{
+		{
+			name:     "defaults and allocated values are ignored",
+			resource: newDriftTestLiveService(managedFields(FieldManager, metav1.ManagedFieldsOperationApply, driftTestServiceFields)),
+			desired:  newDriftTestService(3000, 3001),
+		},
+		{
+			name:     "changed field",
+			resource: newDriftTestLiveService(managedFields(FieldManager, metav1.ManagedFieldsOperationApply, driftTestServiceFields)),
+			desired:  newDriftTestService(3000, 4000),
+			drifted:  true,
+		},
+		{
+			name:     "owned item removed from the desired state",
+			resource: newDriftTestLiveService(managedFields(FieldManager, metav1.ManagedFieldsOperationApply, driftTestServiceFields)),
+			desired:  newDriftTestService(3000),
+			drifted:  true,
+		},
+		{
+			name: "item added by another manager is kept",
+			resource: newDriftTestLiveService(
+				managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:selector":{"f:app":{}},"f:ports":{"k:{\"port\":80,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:targetPort":{}}}}}`),
+				managedFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:ports":{"k:{\"port\":81,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:targetPort":{}}}}}`),
+			),
+			desired: newDriftTestService(3000),
+		},
+		{
+			name: "field taken over by another manager",
+			resource: newDriftTestLiveService(
+				managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:selector":{"f:app":{}},"f:ports":{`+
+					`"k:{\"port\":80,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:targetPort":{}},"k:{\"port\":81,\"protocol\":\"TCP\"}":{".":{},"f:port":{}}}}}`),
+				managedFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:ports":{"k:{\"port\":81,\"protocol\":\"TCP\"}":{"f:targetPort":{}}}}}`),
+			),
+			desired: newDriftTestService(3000, 3002),
+			drifted: true,
+		},
+		{
+			name:     "created before server side apply was used",
+			resource: newDriftTestLiveService(managedFields(FieldManager, metav1.ManagedFieldsOperationUpdate, driftTestServiceFields)),
+			desired:  newDriftTestService(3000, 3001),
+			drifted:  true,
+		},
+		{
+			name: "atomic list owned with its defaults",
+			resource: &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:          "sb-np",
+					Namespace:     "dev",
+					ManagedFields: []metav1.ManagedFieldsEntry{managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:egress":{},"f:podSelector":{}}}`)},
+				},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "sb-sb"}},
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+					Egress:      []networkingv1.NetworkPolicyEgressRule{newDriftTestEgressRule(corev1.ProtocolTCP)},
+				},
+			},
+			desired: &networkingv1.NetworkPolicy{
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "sb-sb"}},
+					Egress:      []networkingv1.NetworkPolicyEgressRule{newDriftTestEgressRule("")},
+				},
+			},
+		},
+		{
+			name: "configmap key added by another manager is kept",
+			resource: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "sb-cm",
+					Namespace: "dev",
+					ManagedFields: []metav1.ManagedFieldsEntry{
+						managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:port":{}}}`),
+						managedFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:extra":{}}}`),
+					},
+				},
+				Data: map[string]string{"port": "3000", "extra": "true"},
+			},
+			desired: &corev1.ConfigMap{Data: map[string]string{"port": "3000"}},
+		},
+		{
+			name: "configmap key removed from the desired state",
+			resource: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:          "sb-cm",
+					Namespace:     "dev",
+					ManagedFields: []metav1.ManagedFieldsEntry{managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:port":{},"f:email":{}}}`)},
+				},
+				Data: map[string]string{"port": "3000", "email": "a@b.c"},
+			},
+			desired: &corev1.ConfigMap{Data: map[string]string{"port": "3000"}},
+			drifted: true,
+		},
+		{
+			name: "configmap up to date",
+			resource: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:          "sb-cm",
+					Namespace:     "dev",
+					ManagedFields: []metav1.ManagedFieldsEntry{managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:port":{}}}`)},
+				},
+				Data: map[string]string{"port": "3000"},
+			},
+			desired: &corev1.ConfigMap{Data: map[string]string{"port": "3000"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			drifted, err := hasDrifted(tc.resource, tc.desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if drifted != tc.drifted {
+				t.Errorf("expected drifted %t, got %t", tc.drifted, drifted)
+			}
+		})
+	}
+}
+
+func newDriftTestEgressRule(protocol corev1.Protocol) networkingv1.NetworkPolicyEgressRule {
+	port := intstr.FromInt(27017)
+	rule := networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Port: &port}},
+	}
+
+	if protocol != "" {
+		rule.Ports[0].Protocol = &protocol
+	}
+
+	return rule
+}
+
+func TestForeignManagers(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				managedFields(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:data":{"f:port":{}}}`),
+				managedFields("kubectl-annotate", metav1.ManagedFieldsOperationUpdate, `{"f:metadata":{"f:annotations":{"f:note":{}}}}`),
+				managedFields("kubectl-edit", metav1.ManagedFieldsOperationUpdate, `{"f:data":{"f:extra":{}}}`),
+			},
+		},
+	}
+
+	managers := foreignManagers(cm)
+	if len(managers) != 1 || managers[0] != "kubectl-edit" {
+		t.Errorf("expected only kubectl-edit, got %v", managers)
+	}
+}
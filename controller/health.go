@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ready returns an error until the caches of all the informers have synced
+// the informers are started on every replica, so standby replicas (leader election) are ready as well
+func (c *Controller) Ready() error {
+	for name, synced := range c.informersSynced() {
+		if !synced() {
+			return fmt.Errorf("cache of %s not synced", name)
+		}
+	}
+
+	return nil
+}
+
+// Healthy returns an error if a worker has been processing the same item for longer than StuckWorkerTimeout
+func (c *Controller) Healthy() error {
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+
+	for key, start := range c.processing {
+		if duration := time.Since(start); duration > StuckWorkerTimeout {
+			return fmt.Errorf("worker stuck on %s for %s", key, duration.Round(time.Second))
+		}
+	}
+
+	return nil
+}
+
+// keeps track of the items being processed so that stuck workers can be detected
+func (c *Controller) startProcessing(key string) {
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+
+	c.processing[key] = time.Now()
+}
+
+func (c *Controller) doneProcessing(key string) {
+	c.processingLock.Lock()
+	defer c.processingLock.Unlock()
+
+	delete(c.processing, key)
+}
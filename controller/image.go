@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// images used when they are not set in the spec, set from the flags of the operator
+var (
+	DefaultImage             = Image
+	DefaultMongoImage        = MongoImage
+	DefaultBackupUploadImage = BackupUploadImage
+)
+
+func appImage(sb *v1alpha1.SocialBook) string {
+	if sb.Spec.Image != "" {
+		return sb.Spec.Image
+	}
+
+	return DefaultImage
+}
+
+// also used by the jobs running mongosh, mongodump and mongorestore so that the tools match the version of the server
+// the image recorded in the status is kept when mongo.image is not set, the default image only applies to new SocialBooks
+func mongoImage(sb *v1alpha1.SocialBook) string {
+	if sb.Spec.Mongo != nil && sb.Spec.Mongo.Image != "" {
+		return sb.Spec.Mongo.Image
+	}
+
+	if sb.Status.MongoImage != "" {
+		return sb.Status.MongoImage
+	}
+
+	return DefaultMongoImage
+}
+
+// image of mongodb the SocialBook runs, recorded in the status so that upgrading the operator doesn't upgrade mongodb (it doesn't
+// start on the data files of a version more than one release older), SocialBooks deployed by versions of the operator which
+// didn't record it keep the image of their statefulset (or of the deployment used before the statefulset)
+func (c *Controller) deployedMongoImage(sb *v1alpha1.SocialBook) string {
+	if sb.Spec.Mongo != nil && sb.Spec.Mongo.Image != "" || sb.Status.MongoImage != "" {
+		return mongoImage(sb)
+	}
+
+	if sts, err := c.statefulSetLister.StatefulSets(sb.Namespace).Get(sb.Name + MongoDB); err == nil {
+		if image := containerImage(&sts.Spec.Template.Spec, sb.Name+MongoDB); image != "" {
+			return image
+		}
+	}
+
+	if dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(sb.Name + MongoDB); err == nil {
+		if image := containerImage(&dep.Spec.Template.Spec, sb.Name+MongoDB); image != "" {
+			return image
+		}
+	}
+
+	return DefaultMongoImage
+}
+
+func containerImage(podSpec *corev1.PodSpec, name string) string {
+	for _, container := range podSpec.Containers {
+		if container.Name == name {
+			return container.Image
+		}
+	}
+
+	return ""
+}
+
+// the replica set and connectivity check jobs run mongosh, which is only shipped in the images of mongodb 5.0 and later
+// only tags starting with a version are checked, other tags (and digests) are trusted
+func ValidateMongoImage(image string) error {
+	image, _, _ = strings.Cut(image, "@")
+
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+
+	// major version of tags like 6, 6.0, 6.0.5 or 6.0-jammy
+	digits := strings.IndexFunc(tag, func(r rune) bool { return r < '0' || r > '9' })
+	if digits == -1 {
+		digits = len(tag)
+	}
+
+	major, err := strconv.Atoi(tag[:digits])
+	if err != nil {
+		return nil
+	}
+
+	if major < MinMongoVersion {
+		return fmt.Errorf("mongodb image %s is not supported, mongodb %d.0 or later is required", image, MinMongoVersion)
+	}
+
+	return nil
+}
+
+// sets the pull policy of all the containers and the pull secrets of the pod, nothing is set when they are not passed
+// so that the pods of existing SocialBooks are not rolled
+func setImagePullOptions(sb *v1alpha1.SocialBook, podSpec *corev1.PodSpec) {
+	podSpec.ImagePullSecrets = sb.Spec.ImagePullSecrets
+
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].ImagePullPolicy = sb.Spec.ImagePullPolicy
+	}
+
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].ImagePullPolicy = sb.Spec.ImagePullPolicy
+	}
+}
+
+// records an event when the image of a container changes, the pods are then replaced by the rolling update of the workload
+func (c *Controller) recordImageChange(sb *v1alpha1.SocialBook, kind string, name string, current *corev1.PodSpec, desired *corev1.PodSpec) {
+	images := map[string]string{}
+	for _, container := range current.Containers {
+		images[container.Name] = container.Image
+	}
+
+	for _, container := range desired.Containers {
+		if image, ok := images[container.Name]; ok && image != container.Image {
+			c.recorder.Eventf(sb, corev1.EventTypeNormal, "RollingOut", "Rolling out image %s to %s %s (was %s)", container.Image, kind, name, image)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestMongoStatefulSet(sb *v1alpha1.SocialBook, image string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sb.Name + MongoDB,
+			Namespace: sb.Namespace,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: sb.Name + MongoDB, Image: image}},
+				},
+			},
+		},
+	}
+}
+
+func TestDeployedMongoImage(t *testing.T) {
+	sb := newTestSocialBook()
+
+	withImage := sb.DeepCopy()
+	withImage.Spec.Mongo = &v1alpha1.MongoSpec{Image: "mongo:7.0"}
+
+	recorded := sb.DeepCopy()
+	recorded.Status.MongoImage = "mongo:6.0"
+
+	cases := []struct {
+		name     string
+		sb       *v1alpha1.SocialBook
+		objects  []runtime.Object
+		expected string
+	}{
+		{
+			name:     "new SocialBook",
+			sb:       sb,
+			expected: DefaultMongoImage,
+		},
+		{
+			name:     "image of the spec",
+			sb:       withImage,
+			objects:  []runtime.Object{newTestMongoStatefulSet(sb, "mongo:6.0")},
+			expected: "mongo:7.0",
+		},
+		{
+			name:     "image recorded in the status",
+			sb:       recorded,
+			objects:  []runtime.Object{newTestMongoStatefulSet(sb, "mongo:5.0")},
+			expected: "mongo:6.0",
+		},
+		{
+			name:     "deployed by an older operator",
+			sb:       sb,
+			objects:  []runtime.Object{newTestMongoStatefulSet(sb, "mongo")},
+			expected: "mongo",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestController(t, tc.objects...)
+			if image := c.deployedMongoImage(tc.sb); image != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, image)
+			}
+		})
+	}
+}
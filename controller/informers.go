@@ -0,0 +1,153 @@
+package controller
+
+import (
+	stderrors "errors"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+var errReadOnlyIndexer = stderrors.New("indexer of several namespaces is read only")
+
+// informers of the same resource, one for every watched namespace (a single one when all namespaces are watched)
+type namespacedInformers []cache.SharedIndexInformer
+
+func (n namespacedInformers) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, informer := range n {
+		informer.AddEventHandler(handler)
+	}
+}
+
+func (n namespacedInformers) HasSynced() bool {
+	for _, informer := range n {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexer used to build a single lister over all the watched namespaces
+func (n namespacedInformers) Indexer() cache.Indexer {
+	if len(n) == 1 {
+		return n[0].GetIndexer()
+	}
+
+	indexers := make(multiNamespaceIndexer, 0, len(n))
+	for _, informer := range n {
+		indexers = append(indexers, informer.GetIndexer())
+	}
+
+	return indexers
+}
+
+// read only view over the indexers of several namespaces, every object is stored in exactly one of them
+// the write methods are never called by the listers and are not supported
+type multiNamespaceIndexer []cache.Indexer
+
+func (m multiNamespaceIndexer) List() []interface{} {
+	var items []interface{}
+	for _, indexer := range m {
+		items = append(items, indexer.List()...)
+	}
+	return items
+}
+
+func (m multiNamespaceIndexer) ListKeys() []string {
+	var keys []string
+	for _, indexer := range m {
+		keys = append(keys, indexer.ListKeys()...)
+	}
+	return keys
+}
+
+func (m multiNamespaceIndexer) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return m.GetByKey(key)
+}
+
+func (m multiNamespaceIndexer) GetByKey(key string) (interface{}, bool, error) {
+	for _, indexer := range m {
+		item, exists, err := indexer.GetByKey(key)
+		if err != nil || exists {
+			return item, exists, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (m multiNamespaceIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var items []interface{}
+	for _, indexer := range m {
+		indexed, err := indexer.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, indexed...)
+	}
+	return items, nil
+}
+
+func (m multiNamespaceIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	for _, indexer := range m {
+		indexed, err := indexer.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, indexed...)
+	}
+	return keys, nil
+}
+
+func (m multiNamespaceIndexer) ListIndexFuncValues(indexName string) []string {
+	var values []string
+	for _, indexer := range m {
+		values = append(values, indexer.ListIndexFuncValues(indexName)...)
+	}
+	return values
+}
+
+func (m multiNamespaceIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var items []interface{}
+	for _, indexer := range m {
+		indexed, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, indexed...)
+	}
+	return items, nil
+}
+
+// all the informers of a factory are created with the same indexers
+func (m multiNamespaceIndexer) GetIndexers() cache.Indexers {
+	return m[0].GetIndexers()
+}
+
+func (m multiNamespaceIndexer) Add(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m multiNamespaceIndexer) Update(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m multiNamespaceIndexer) Delete(obj interface{}) error {
+	return errReadOnlyIndexer
+}
+
+func (m multiNamespaceIndexer) Replace(items []interface{}, resourceVersion string) error {
+	return errReadOnlyIndexer
+}
+
+func (m multiNamespaceIndexer) Resync() error {
+	return errReadOnlyIndexer
+}
+
+func (m multiNamespaceIndexer) AddIndexers(newIndexers cache.Indexers) error {
+	return errReadOnlyIndexer
+}
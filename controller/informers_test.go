@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestConfigMap(namespace string, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+func TestMultiNamespaceIndexer(t *testing.T) {
+	indexer := multiNamespaceIndexer{
+		newTestIndexer(t, newTestConfigMap("dev", "a"), newTestConfigMap("dev", "b")),
+		newTestIndexer(t, newTestConfigMap("prod", "a")),
+	}
+
+	keys := indexer.ListKeys()
+	sort.Strings(keys)
+	if len(indexer.List()) != 3 || len(keys) != 3 || keys[0] != "dev/a" || keys[2] != "prod/a" {
+		t.Errorf("expected the items of both namespaces, got %v", keys)
+	}
+
+	item, exists, err := indexer.GetByKey("prod/a")
+	if err != nil || !exists || item.(*corev1.ConfigMap).Namespace != "prod" {
+		t.Errorf("expected prod/a, got %v %t %v", item, exists, err)
+	}
+
+	if _, exists, _ := indexer.GetByKey("prod/b"); exists {
+		t.Error("expected prod/b not to exist")
+	}
+
+	items, err := indexer.ByIndex(cache.NamespaceIndex, "dev")
+	if err != nil || len(items) != 2 {
+		t.Errorf("expected 2 items in dev, got %d %v", len(items), err)
+	}
+
+	if err := indexer.Add(newTestConfigMap("dev", "c")); err != errReadOnlyIndexer {
+		t.Errorf("expected %v, got %v", errReadOnlyIndexer, err)
+	}
+
+	if err := indexer.Delete(newTestConfigMap("dev", "a")); err != errReadOnlyIndexer {
+		t.Errorf("expected %v, got %v", errReadOnlyIndexer, err)
+	}
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const metricsNamespace = "socialbook_operator"
+
+var (
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Time taken to reconcile a SocialBook.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	})
+
+	reconcileErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of reconciles of a SocialBook which failed.",
+	})
+
+	resourceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_reconcile_duration_seconds",
+		Help:      "Time taken to reconcile a resource owned by a SocialBook, by kind.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"kind"})
+
+	resourceErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_reconcile_errors_total",
+		Help:      "Number of errors while reconciling a resource owned by a SocialBook, by kind.",
+	}, []string{"kind"})
+
+	// workqueue metrics, labeled with the name of the queue
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Current depth of the workqueue.",
+	}, []string{"name"})
+
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "adds_total",
+		Help:      "Number of adds handled by the workqueue.",
+	}, []string{"name"})
+
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "queue_duration_seconds",
+		Help:      "Time an item stays in the workqueue before being processed.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"name"})
+
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "work_duration_seconds",
+		Help:      "Time taken to process an item from the workqueue.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"name"})
+
+	workqueueUnfinishedWork = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "unfinished_work_seconds",
+		Help:      "Seconds of work in progress which has not been observed by work_duration_seconds yet.",
+	}, []string{"name"})
+
+	workqueueLongestRunningProcessor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "longest_running_processor_seconds",
+		Help:      "Seconds the longest running item of the workqueue has been processed for.",
+	}, []string{"name"})
+
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "workqueue",
+		Name:      "retries_total",
+		Help:      "Number of retries handled by the workqueue.",
+	}, []string{"name"})
+
+	socialbooksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "socialbooks"),
+		"Number of SocialBooks by component and phase.",
+		[]string{"component", "phase"}, nil,
+	)
+
+	informerSyncedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "informer_synced"),
+		"Whether the cache of the informer has synced (1) or not (0).",
+		[]string{"informer"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		reconcileDuration,
+		reconcileErrors,
+		resourceDuration,
+		resourceErrors,
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueUnfinishedWork,
+		workqueueLongestRunningProcessor,
+		workqueueRetries,
+	)
+
+	// has to be set before the queue is created
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// records the duration of reconciling a resource of the given kind and counts it as an error if err is not nil
+func observeResource(kind string, start time.Time, err error) {
+	resourceDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		resourceErrors.WithLabelValues(kind).Inc()
+	}
+}
+
+// prometheus metrics for the workqueue used by the controller
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunningProcessor.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+// metrics computed from the state of the controller when they are scraped
+type stateCollector struct {
+	controller *Controller
+}
+
+func (s *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- socialbooksDesc
+	ch <- informerSyncedDesc
+}
+
+func (s *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	c := s.controller
+
+	for name, synced := range c.informersSynced() {
+		value := 0.0
+		if synced() {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(informerSyncedDesc, prometheus.GaugeValue, value, name)
+	}
+
+	sbs, err := c.socialbookLister.List(labels.Everything())
+
+	if err != nil {
+		log.Printf("Error %s while listing socialbooks for metrics", err.Error())
+		return
+	}
+
+	counts := map[string]map[string]int{
+		"mongo":      {Pending: 0, Success: 0, Failure: 0},
+		"socialbook": {Pending: 0, Success: 0, Failure: 0},
+	}
+
+	for _, sb := range sbs {
+		if sb.Status.MongoDB != "" {
+			counts["mongo"][sb.Status.MongoDB]++
+		}
+		if sb.Status.SocialBook != "" {
+			counts["socialbook"][sb.Status.SocialBook]++
+		}
+	}
+
+	for component, phases := range counts {
+		for phase, count := range phases {
+			ch <- prometheus.MustNewConstMetric(socialbooksDesc, prometheus.GaugeValue, float64(count), component, phase)
+		}
+	}
+}
+
+// HasSynced functions of all the informers used by the controller, by resource
+func (c *Controller) informersSynced() map[string]cache.InformerSynced {
+	return map[string]cache.InformerSynced{
+		"socialbooks":            c.socialbookSynced,
+		"configmaps":             c.configMapSynced,
+		"secrets":                c.secretSynced,
+		"persistentvolumes":      c.pvSynced,
+		"persistentvolumeclaims": c.pvcSynced,
+		"services":               c.serviceSynced,
+		"deployments":            c.deploymentSynced,
+		"statefulsets":           c.statefulSetSynced,
+		"networkpolicies":        c.networkPolicySynced,
+		"pods":                   c.podSynced,
+		"jobs":                   c.jobSynced,
+		"cronjobs":               c.cronJobSynced,
+		"socialbookrestores":     c.restoreSynced,
+	}
+}
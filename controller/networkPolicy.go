@@ -2,6 +2,7 @@ package controller
 
 import (
 	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -17,6 +18,10 @@ func newNetworkPolicy(sb *v1alpha1.SocialBook, appType string) *networkingv1.Net
 func newMongoNetworkPolicy(sb *v1alpha1.SocialBook) *networkingv1.NetworkPolicy {
 	port := intstr.FromInt(27017)
 	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            sb.Name + MongoDB + NetworkPolicy,
 			Namespace:       sb.Namespace,
@@ -38,6 +43,38 @@ func newMongoNetworkPolicy(sb *v1alpha1.SocialBook) *networkingv1.NetworkPolicy
 								},
 							},
 						},
+						// members of the replica set
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": sb.Name + MongoDB,
+								},
+							},
+						},
+						// job configuring the replica set
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": sb.Name + MongoDB + ReplicaSetJob,
+								},
+							},
+						},
+						// backup jobs
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": sb.Name + Backup,
+								},
+							},
+						},
+						// restore jobs
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"app": sb.Name + Restore,
+								},
+							},
+						},
 					},
 					Ports: []networkingv1.NetworkPolicyPort{
 						{
@@ -51,8 +88,16 @@ func newMongoNetworkPolicy(sb *v1alpha1.SocialBook) *networkingv1.NetworkPolicy
 }
 
 func newSocialBookNetworkPolicy(sb *v1alpha1.SocialBook) *networkingv1.NetworkPolicy {
+	if external := externalDatabase(sb); external != nil {
+		return newExternalDatabaseNetworkPolicy(sb, external)
+	}
+
 	port := intstr.FromInt(27017)
 	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            sb.Name + NetworkPolicy,
 			Namespace:       sb.Namespace,
@@ -81,6 +126,73 @@ func newSocialBookNetworkPolicy(sb *v1alpha1.SocialBook) *networkingv1.NetworkPo
 						},
 					},
 				},
+				// the service of mongodb is resolved through the cluster dns
+				dnsEgressRule(),
+			},
+		},
+	}
+}
+
+// egress to the cluster dns, the dns pods aren't selected as they run in another namespace with labels depending on the distribution
+func dnsEgressRule() networkingv1.NetworkPolicyEgressRule {
+	dnsPort := intstr.FromInt(53)
+	udp := corev1.ProtocolUDP
+	tcp := corev1.ProtocolTCP
+
+	return networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{
+			{
+				Protocol: &udp,
+				Port:     &dnsPort,
+			},
+			{
+				Protocol: &tcp,
+				Port:     &dnsPort,
+			},
+		},
+	}
+}
+
+// socialbook pods connect to the external database outside the cluster, its host is resolved through the cluster dns
+func newExternalDatabaseNetworkPolicy(sb *v1alpha1.SocialBook, external *v1alpha1.ExternalDatabaseSpec) *networkingv1.NetworkPolicy {
+	port := intstr.FromInt(externalDatabasePort(external))
+
+	// all addresses are allowed when no cidrs are passed
+	var to []networkingv1.NetworkPolicyPeer
+	for _, cidr := range external.EgressCIDRs {
+		to = append(to, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR: cidr,
+			},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            sb.Name + NetworkPolicy,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": sb.Name + SocialBook,
+				},
+			},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{
+					To: to,
+					Ports: []networkingv1.NetworkPolicyPort{
+						{
+							Port: &port,
+						},
+					},
+				},
+				dnsEgressRule(),
 			},
 		},
 	}
@@ -1,26 +1,40 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
 	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
+// only created when storage.hostPath is set, otherwise the volume is provisioned by the storage class of the pvc
 func newPersistentVolume(sb *v1alpha1.SocialBook) *corev1.PersistentVolume {
 	pvName := sb.Name + PersistentVolume
 	pv := &corev1.PersistentVolume{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolume",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            pvName,
 			OwnerReferences: setOwnerReference(sb),
+			Labels: map[string]string{
+				NamespaceLabel: sb.Namespace,
+			},
 		},
 		Spec: corev1.PersistentVolumeSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{
-				"ReadWriteOnce",
-			},
+			AccessModes:                   storageAccessModes(sb),
 			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
 			Capacity: corev1.ResourceList{
-				corev1.ResourceName(corev1.ResourceStorage): resource.MustParse("1Gi"),
+				corev1.ResourceName(corev1.ResourceStorage): storageSize(sb),
 			},
 			ClaimRef: &corev1.ObjectReference{
 				Namespace: sb.Namespace,
@@ -28,7 +42,7 @@ func newPersistentVolume(sb *v1alpha1.SocialBook) *corev1.PersistentVolume {
 			},
 			PersistentVolumeSource: corev1.PersistentVolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/tmp/data",
+					Path: sb.Spec.Storage.HostPath,
 				},
 			},
 		},
@@ -40,22 +54,225 @@ func newPersistentVolume(sb *v1alpha1.SocialBook) *corev1.PersistentVolume {
 func newPersistentVolumeClaim(sb *v1alpha1.SocialBook) *corev1.PersistentVolumeClaim {
 	pvcName := sb.Name + PersistentVolumeClaim
 	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            pvcName,
 			Namespace:       sb.Namespace,
 			OwnerReferences: setOwnerReference(sb),
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{
-				"ReadWriteOnce",
+			AccessModes: storageAccessModes(sb),
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceName(corev1.ResourceStorage): storageSize(sb),
+				},
+			},
+		},
+	}
+
+	if sb.Spec.Storage == nil {
+		return pvc
+	}
+
+	if sb.Spec.Storage.HostPath != "" {
+		// bound to the static pv, the empty storage class disables dynamic provisioning
+		noStorageClass := ""
+		pvc.Spec.StorageClassName = &noStorageClass
+		pvc.Spec.VolumeName = sb.Name + PersistentVolume
+		return pvc
+	}
+
+	pvc.Spec.StorageClassName = sb.Spec.Storage.StorageClassName
+
+	return pvc
+}
+
+// keeps the current request in the desired claim if the requested size can't be applied
+// claims can't be shrunk and can only be expanded if their storage class allows it, the refusal is kept in the ResizeRefused condition
+func (c *Controller) resizeClaim(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, pvc *corev1.PersistentVolumeClaim, desired *corev1.PersistentVolumeClaim) error {
+	current, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok {
+		return nil
+	}
+
+	requested := desired.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	switch requested.Cmp(current) {
+	case 0:
+		c.setCondition(sbCopy, ConditionResizeRefused, false, "AsExpected", "")
+		return nil
+	case -1:
+		c.refuseResize(sb, sbCopy, "ShrinkRefused", fmt.Sprintf("storage.size %s can't be applied, volume claims can't be shrunk from %s", requested.String(), current.String()))
+		desired.Spec.Resources.Requests[corev1.ResourceStorage] = current
+		return nil
+	}
+
+	expandable, err := c.allowsVolumeExpansion(pvc.Spec.StorageClassName)
+	if err != nil {
+		return err
+	}
+
+	if !expandable {
+		c.refuseResize(sb, sbCopy, "ExpansionNotSupported", fmt.Sprintf("storage.size %s can't be applied, the storage class of the volume claims does not allow volume expansion", requested.String()))
+		desired.Spec.Resources.Requests[corev1.ResourceStorage] = current
+		return nil
+	}
+
+	c.setCondition(sbCopy, ConditionResizeRefused, false, "AsExpected", "")
+	c.recorder.Eventf(sb, corev1.EventTypeNormal, "Resizing", "Expanding volume claim %s from %s to %s", pvc.Name, current.String(), requested.String())
+	return nil
+}
+
+// the warning event is only recorded when the refusal changes (the requested size was changed), not on every reconcile
+// the messages don't depend on the claim, so that the claims of a replica set don't record it in turns
+func (c *Controller) refuseResize(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, reason string, message string) {
+	if condition := meta.FindStatusCondition(sbCopy.Status.Conditions, ConditionResizeRefused); condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+		c.recorder.Event(sb, corev1.EventTypeWarning, reason, message)
+	}
+
+	c.setCondition(sbCopy, ConditionResizeRefused, true, reason, message)
+}
+
+// storage classes are only read when a claim is expanded, so they are fetched directly instead of being watched
+// the namespaced install can't read them (they are cluster scoped), the claim is then treated as not expandable
+func (c *Controller) allowsVolumeExpansion(storageClassName *string) (bool, error) {
+	// static volumes (hostPath) don't have a storage class
+	if storageClassName == nil || *storageClassName == "" {
+		return false, nil
+	}
+
+	storageClass, err := c.clientset.StorageV1().StorageClasses().Get(context.Background(), *storageClassName, metav1.GetOptions{})
+	if errors.IsForbidden(err) {
+		log.Printf("Storage class %s can't be read, volume expansion is not used: %s", *storageClassName, err.Error())
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// claims of the mongodb pods, created by the statefulset controller from the template
+func newVolumeClaimTemplate(sb *v1alpha1.SocialBook) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: MongoDataVolume,
+			Labels: map[string]string{
+				"app": sb.Name + MongoDB,
 			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: storageAccessModes(sb),
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{
-					corev1.ResourceName(corev1.ResourceStorage): resource.MustParse("1Gi"),
+					corev1.ResourceName(corev1.ResourceStorage): storageSize(sb),
 				},
 			},
 		},
 	}
 
+	if sb.Spec.Storage != nil {
+		pvc.Spec.StorageClassName = sb.Spec.Storage.StorageClassName
+	}
+
+	// the volume is provisioned from a VolumeSnapshot, the template can't be changed afterwards so this is only used for new SocialBooks
+	if sb.Spec.Storage != nil && sb.Spec.Storage.SnapshotName != "" {
+		apiGroup := volumeSnapshotResource.Group
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     "VolumeSnapshot",
+			Name:     sb.Spec.Storage.SnapshotName,
+		}
+	}
+
 	return pvc
 }
+
+// only the requested size of a claim created from the volumeClaimTemplates is changed, the claim itself is managed by the statefulset controller
+func newClaimResize(sb *v1alpha1.SocialBook, pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvc.Name,
+			Namespace: pvc.Namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceName(corev1.ResourceStorage): storageSize(sb),
+				},
+			},
+		},
+	}
+}
+
+// the requested storage of a claim created from the volumeClaimTemplates is owned by the statefulset controller (kube-controller-manager),
+// a merge patch only touching it is sent instead of an apply, which would conflict with that manager
+func (c *Controller) patchClaimSize(sb *v1alpha1.SocialBook, desired *corev1.PersistentVolumeClaim) error {
+	size := desired.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]string{
+					string(corev1.ResourceStorage): size.String(),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clientset.CoreV1().PersistentVolumeClaims(sb.Namespace).Patch(context.Background(), desired.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
+
+// claim mounted by all the mongodb pods: an existing claim, the claim bound to the static pv or the claim of a SocialBook created
+// before mongodb was run as a statefulset, empty if the claims are created from the volumeClaimTemplates of the statefulset
+func (c *Controller) mongoClaimName(sb *v1alpha1.SocialBook) string {
+	if sb.Spec.Storage != nil && sb.Spec.Storage.ExistingClaim != "" {
+		return sb.Spec.Storage.ExistingClaim
+	}
+
+	if sb.Spec.Storage != nil && sb.Spec.Storage.HostPath != "" {
+		return sb.Name + PersistentVolumeClaim
+	}
+
+	if pvc, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(sb.Name + PersistentVolumeClaim); err == nil && metav1.IsControlledBy(pvc, sb) {
+		return sb.Name + PersistentVolumeClaim
+	}
+
+	return ""
+}
+
+// name of the claim of the first mongodb pod, used for the status
+func (c *Controller) firstMongoClaimName(sb *v1alpha1.SocialBook) string {
+	if claimName := c.mongoClaimName(sb); claimName != "" {
+		return claimName
+	}
+
+	return MongoDataVolume + "-" + sb.Name + MongoDB + "-0"
+}
+
+func storageSize(sb *v1alpha1.SocialBook) resource.Quantity {
+	if sb.Spec.Storage != nil && sb.Spec.Storage.Size != nil {
+		return *sb.Spec.Storage.Size
+	}
+
+	return resource.MustParse(DefaultStorageSize)
+}
+
+func storageAccessModes(sb *v1alpha1.SocialBook) []corev1.PersistentVolumeAccessMode {
+	if sb.Spec.Storage != nil && len(sb.Spec.Storage.AccessModes) > 0 {
+		return sb.Spec.Storage.AccessModes
+	}
+
+	return []corev1.PersistentVolumeAccessMode{
+		corev1.ReadWriteOnce,
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestClaim(name string, storageClassName string, size string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "dev",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func TestResizeClaimRefused(t *testing.T) {
+	allowExpansion := false
+	c := newTestController(t, &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+		AllowVolumeExpansion: &allowExpansion,
+	})
+	events := c.recorder.(*record.FakeRecorder).Events
+
+	sb := newTestSocialBook()
+	sbCopy := sb.DeepCopy()
+
+	// the claims of a replica set, resized on every reconcile while the spec isn't changed
+	claims := []*corev1.PersistentVolumeClaim{newTestClaim("data-sb-mongo-0", "standard", "2Gi"), newTestClaim("data-sb-mongo-1", "standard", "2Gi")}
+	for i := 0; i < 3; i++ {
+		for _, pvc := range claims {
+			desired := newTestClaim(pvc.Name, "standard", "1Gi")
+			if err := c.resizeClaim(sb, sbCopy, pvc, desired); err != nil {
+				t.Fatal(err)
+			}
+
+			if requested := desired.Spec.Resources.Requests[corev1.ResourceStorage]; requested.String() != "2Gi" {
+				t.Errorf("expected the claim to keep 2Gi, got %s", requested.String())
+			}
+		}
+	}
+
+	if len(events) != 1 {
+		t.Errorf("expected 1 event, got %d", len(events))
+	}
+
+	condition := meta.FindStatusCondition(sbCopy.Status.Conditions, ConditionResizeRefused)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "ShrinkRefused" {
+		t.Fatalf("expected condition %s to be true with reason ShrinkRefused, got %v", ConditionResizeRefused, condition)
+	}
+
+	// a new requested size is refused again
+	if err := c.resizeClaim(sb, sbCopy, claims[0], newTestClaim(claims[0].Name, "standard", "4Gi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+
+	if condition = meta.FindStatusCondition(sbCopy.Status.Conditions, ConditionResizeRefused); condition.Reason != "ExpansionNotSupported" {
+		t.Errorf("expected reason ExpansionNotSupported, got %s", condition.Reason)
+	}
+
+	// the refusal is cleared once the size is applied
+	if err := c.resizeClaim(sb, sbCopy, claims[0], newTestClaim(claims[0].Name, "standard", "2Gi")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !meta.IsStatusConditionFalse(sbCopy.Status.Conditions, ConditionResizeRefused) {
+		t.Errorf("expected condition %s to be false", ConditionResizeRefused)
+	}
+}
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// initiates the replica set on the first member and then adds and removes members one at a time until it matches MONGO_MEMBERS
+// it is run against the primary, which is stepped down first if it is removed, and can be run any number of times
+const replicaSetScript = `
+const members = JSON.parse(process.env.MONGO_MEMBERS);
+const uri = (host) => "mongodb://" + encodeURIComponent(process.env.MONGO_USERNAME) + ":" + encodeURIComponent(process.env.MONGO_PASSWORD) + "@" + host + "/admin?directConnection=true";
+
+const primary = () => {
+  for (;;) {
+    try {
+      const hello = connect(uri(members[0])).hello();
+      if (hello.primary) {
+        return connect(uri(hello.primary));
+      }
+    } catch (e) {
+      print("waiting for the primary: " + e);
+    }
+    sleep(1000);
+  }
+};
+
+try {
+  connect(uri(members[0])).adminCommand({ replSetGetStatus: 1 });
+} catch (e) {
+  if (e.codeName !== "NotYetInitialized") {
+    throw e;
+  }
+  print("initiating replica set " + process.env.MONGO_REPLICA_SET);
+  connect(uri(members[0])).adminCommand({ replSetInitiate: { _id: process.env.MONGO_REPLICA_SET, members: [{ _id: 0, host: members[0] }] } });
+}
+
+db = primary();
+
+for (const host of members) {
+  if (!rs.conf().members.some((member) => member.host === host)) {
+    print("adding " + host);
+    rs.add(host);
+  }
+}
+
+for (const member of rs.conf().members) {
+  if (members.includes(member.host)) {
+    continue;
+  }
+  while (db.hello().me === member.host) {
+    print("stepping down " + member.host);
+    try {
+      db.adminCommand({ replSetStepDown: 60 });
+    } catch (e) {
+      print(e);
+    }
+    db = primary();
+  }
+  print("removing " + member.host);
+  rs.remove(member.host);
+}
+`
+
+// mongodb runs as a replica set when mongo.replicas is set, even with a single member so that members can be added later
+func replicaSetMode(sb *v1alpha1.SocialBook) bool {
+	return sb.Spec.Mongo != nil && sb.Spec.Mongo.Replicas > 0 && externalDatabase(sb) == nil
+}
+
+// number of members requested in the spec, 1 for a standalone mongodb
+func mongoReplicas(sb *v1alpha1.SocialBook) int32 {
+	if replicaSetMode(sb) {
+		return sb.Spec.Mongo.Replicas
+	}
+
+	return 1
+}
+
+// addresses of the members of the replica set, the pods of the statefulset have stable names through the headless service
+func replicaSetMembers(sb *v1alpha1.SocialBook) []string {
+	members := make([]string, 0, mongoReplicas(sb))
+
+	for i := int32(0); i < mongoReplicas(sb); i++ {
+		members = append(members, fmt.Sprintf("%s-%d.%s.%s.svc:27017", sb.Name+MongoDB, i, sb.Name+MongoDB+Headless, sb.Namespace))
+	}
+
+	return members
+}
+
+// job configuring the members of the replica set, its name contains the number of members so that a new job is run when it is scaled
+func newReplicaSetJob(sb *v1alpha1.SocialBook) *batchv1.Job {
+	secretName := sb.Name + Secret
+	members, _ := json.Marshal(replicaSetMembers(sb))
+
+	var backoffLimit int32
+	backoffLimit = 10
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            replicaSetJobName(sb),
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": sb.Name + MongoDB + ReplicaSetJob,
+					},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "replica-set",
+							Image:   mongoImage(sb),
+							Command: []string{"mongosh", "--nodb", "--quiet", "--eval", replicaSetScript},
+							Env: []corev1.EnvVar{
+								{
+									Name:  "MONGO_MEMBERS",
+									Value: string(members),
+								},
+								{
+									Name:  "MONGO_REPLICA_SET",
+									Value: ReplicaSetName,
+								},
+								{
+									Name: "MONGO_USERNAME",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: secretName,
+											},
+											Key: "mongo-root-username",
+										},
+									},
+								},
+								{
+									Name: "MONGO_PASSWORD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: secretName,
+											},
+											Key: "mongo-root-password",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	setImagePullOptions(sb, &job.Spec.Template.Spec)
+
+	return job
+}
+
+func replicaSetJobName(sb *v1alpha1.SocialBook) string {
+	return sb.Name + MongoDB + ReplicaSetJob + "-" + strconv.Itoa(int(mongoReplicas(sb)))
+}
+
+// runs the job configuring the replica set once the pods of all the requested members are ready (members are added after the statefulset is scaled up
+// and removed before it is scaled down), status.replicaSetMembers is set once the job has succeeded
+func (c *Controller) handleReplicaSet(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) error {
+	replicas := mongoReplicas(sb)
+
+	if !replicaSetMode(sb) {
+		sbCopy.Status.ReplicaSetMembers = 0
+		return nil
+	}
+
+	if sb.Status.ReplicaSetMembers == replicas {
+		return nil
+	}
+
+	sts, err := c.statefulSetLister.StatefulSets(sb.Namespace).Get(sb.Name + MongoDB)
+	if err != nil || sts.Status.ReadyReplicas < replicas {
+		return nil
+	}
+
+	jobName := replicaSetJobName(sb)
+	job, err := c.jobLister.Jobs(sb.Namespace).Get(jobName)
+
+	if err = c.handleResource(err, job, sb, newReplicaSetJob(sb)); err != nil || job == nil {
+		return err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchv1.JobComplete:
+			sbCopy.Status.ReplicaSetMembers = replicas
+			c.recorder.Eventf(sb, corev1.EventTypeNormal, "ReplicaSetConfigured", "Replica set %s configured with %d members", ReplicaSetName, replicas)
+		case batchv1.JobFailed:
+			c.recorder.Eventf(sb, corev1.EventTypeWarning, "ReplicaSetFailed", "Job %s failed: %s", jobName, condition.Message)
+		default:
+			continue
+		}
+
+		// finished jobs are deleted, a failed job is created again on the next reconcile
+		propagation := metav1.DeletePropagationBackground
+		err = c.clientset.BatchV1().Jobs(sb.Namespace).Delete(context.Background(), jobName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		if condition.Type == batchv1.JobFailed {
+			return fmt.Errorf("job %s configuring the replica set failed: %s", jobName, condition.Message)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+)
+
+func TestReplicaSetMembers(t *testing.T) {
+	sb := newTestSocialBook()
+
+	standalone := []string{"sb-mongo-0.sb-mongo-hl.dev.svc:27017"}
+	if members := replicaSetMembers(sb); !reflect.DeepEqual(members, standalone) {
+		t.Errorf("expected %v, got %v", standalone, members)
+	}
+
+	sb.Spec.Mongo = &v1alpha1.MongoSpec{Replicas: 3}
+	expected := []string{
+		"sb-mongo-0.sb-mongo-hl.dev.svc:27017",
+		"sb-mongo-1.sb-mongo-hl.dev.svc:27017",
+		"sb-mongo-2.sb-mongo-hl.dev.svc:27017",
+	}
+	if members := replicaSetMembers(sb); !reflect.DeepEqual(members, expected) {
+		t.Errorf("expected %v, got %v", expected, members)
+	}
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// phases of a SocialBookRestore
+const (
+	RestorePending     = "Pending"
+	RestoreScalingDown = "ScalingDown"
+	RestoreRestoring   = "Restoring"
+	RestoreScalingUp   = "ScalingUp"
+	RestoreSucceeded   = "Succeeded"
+	RestoreFailed      = "Failed"
+)
+
+// downloads the archive from the bucket into /backup
+const downloadS3Script = `set -e
+mc alias set target "$S3_ENDPOINT" "$S3_ACCESS_KEY" "$S3_SECRET_KEY" > /dev/null
+mc cp "target/$S3_BUCKET/$S3_PREFIX/$ARTIFACT" "/backup/$ARTIFACT"
+`
+
+// restores the archive of the backup target of the SocialBook, it is owned by the SocialBookRestore
+func newRestoreJob(sb *v1alpha1.SocialBook, restore *v1alpha1.SocialBookRestore) *batchv1.Job {
+	backup := sb.Spec.Backup
+
+	var backoffLimit int32
+	backoffLimit = 2
+
+	args := []string{"--uri=$(MONGODB_URI)", "--gzip", "--archive=/backup/" + restore.Status.Artifact}
+	if restore.Spec.Drop {
+		args = append(args, "--drop")
+	}
+
+	artifact := corev1.EnvVar{
+		Name:  "ARTIFACT",
+		Value: restore.Status.Artifact,
+	}
+
+	podSpec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Containers: []corev1.Container{
+			{
+				Name:    "mongorestore",
+				Image:   mongoImage(sb),
+				Command: append([]string{"mongorestore"}, args...),
+				Env: []corev1.EnvVar{
+					{
+						Name: "MONGODB_URI",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{
+									Name: sb.Name + Secret,
+								},
+								Key: "mongodb-uri",
+							},
+						},
+					},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "backup",
+						MountPath: "/backup",
+					},
+				},
+			},
+		},
+	}
+
+	if backup.Volume != nil {
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: "backup",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: backup.Volume.ClaimName,
+						ReadOnly:  true,
+					},
+				},
+			},
+		}
+	} else {
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name: "backup",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			},
+		}
+
+		podSpec.InitContainers = []corev1.Container{
+			{
+				Name:    "download",
+				Image:   DefaultBackupUploadImage,
+				Command: []string{"sh", "-c", downloadS3Script},
+				Env:     append(s3Env(sb), artifact),
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "backup",
+						MountPath: "/backup",
+					},
+				},
+			},
+		}
+	}
+
+	mountDatabaseCA(sb, &podSpec)
+	setImagePullOptions(sb, &podSpec)
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restore.Name + Restore,
+			Namespace: restore.Namespace,
+			Labels: map[string]string{
+				SocialBookLabel: sb.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(restore, v1alpha1.SchemeGroupVersion.WithKind(RestoreKind)),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app":           sb.Name + Restore,
+						SocialBookLabel: sb.Name,
+					},
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	return job
+}
+
+// moves the oldest unfinished restore of the SocialBook to its next phase, restores are processed one at a time
+// returns true while the socialbook deployment has to be scaled down
+func (c *Controller) handleRestores(sb *v1alpha1.SocialBook) (bool, error) {
+	restores, err := c.restoreLister.SocialBookRestores(sb.Namespace).List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+
+	var pending []*v1alpha1.SocialBookRestore
+	for _, restore := range restores {
+		if restore.Spec.SocialBook == sb.Name && restore.Status.Phase != RestoreSucceeded && restore.Status.Phase != RestoreFailed {
+			pending = append(pending, restore)
+		}
+	}
+
+	if len(pending) == 0 {
+		return false, nil
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreationTimestamp.Before(&pending[j].CreationTimestamp)
+	})
+
+	for _, restore := range pending[1:] {
+		restoreCopy := restore.DeepCopy()
+		restoreCopy.Status.Phase = RestorePending
+		restoreCopy.Status.Message = fmt.Sprintf("waiting for restore %s", pending[0].Name)
+		c.updateRestoreStatus(restore, restoreCopy)
+	}
+
+	restore := pending[0]
+	restoreCopy := restore.DeepCopy()
+	err = c.restore(sb, restoreCopy)
+	c.updateRestoreStatus(restore, restoreCopy)
+
+	if err != nil {
+		return false, err
+	}
+
+	return restoreCopy.Status.Phase == RestoreScalingDown || restoreCopy.Status.Phase == RestoreRestoring, nil
+}
+
+// computes the next phase of the restore, the status is updated by the caller
+func (c *Controller) restore(sb *v1alpha1.SocialBook, restoreCopy *v1alpha1.SocialBookRestore) error {
+	status := &restoreCopy.Status
+
+	switch status.Phase {
+	case "", RestorePending:
+		if sb.Spec.Backup == nil {
+			c.failRestore(sb, restoreCopy, fmt.Sprintf("SocialBook %s has no backup target", sb.Name))
+			return nil
+		}
+
+		status.Artifact = restoreCopy.Spec.Artifact
+		if status.Artifact == "" && sb.Status.Backup != nil {
+			status.Artifact = sb.Status.Backup.LastArtifact
+		}
+
+		if status.Artifact == "" {
+			c.failRestore(sb, restoreCopy, fmt.Sprintf("no artifact passed and SocialBook %s has no successful backup", sb.Name))
+			return nil
+		}
+
+		now := metav1.Now()
+		status.Phase = RestoreScalingDown
+		status.StartTime = &now
+		status.Message = fmt.Sprintf("scaling down deployment %s", sb.Name)
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "RestoreStarted", "Restoring %s (%s), deployment %s is scaled down", status.Artifact, restoreCopy.Name, sb.Name)
+
+	case RestoreScalingDown:
+		// the database is only restored once no pod of the app is left (terminating pods included) and no backup is running
+		dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(sb.Name)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		if dep != nil && dep.Status.Replicas > 0 {
+			return nil
+		}
+
+		pods, err := c.podLister.Pods(sb.Namespace).List(labels.SelectorFromSet(labels.Set{"app": sb.Name + SocialBook}))
+		if err != nil {
+			return err
+		}
+
+		backupRunning, err := c.backupRunning(sb)
+		if err != nil {
+			return err
+		}
+
+		// the pods of the app are not owned by the SocialBook, so their deletion doesn't requeue it
+		if len(pods) > 0 || backupRunning {
+			status.Message = fmt.Sprintf("waiting for the pods of deployment %s and the running backups to stop", sb.Name)
+			c.queue.AddAfter(sb.Namespace+"/"+sb.Name, RequeueInterval)
+			return nil
+		}
+
+		jobName := restoreCopy.Name + Restore
+		if _, err = c.jobLister.Jobs(sb.Namespace).Get(jobName); errors.IsNotFound(err) {
+			if err = c.applyResource(sb, newRestoreJob(sb, restoreCopy)); err != nil {
+				return err
+			}
+		}
+
+		status.Phase = RestoreRestoring
+		status.Message = fmt.Sprintf("running job %s", jobName)
+
+	case RestoreRestoring:
+		jobName := restoreCopy.Name + Restore
+		job, err := c.jobLister.Jobs(sb.Namespace).Get(jobName)
+
+		// the job has been deleted before it finished, so it is started again
+		if errors.IsNotFound(err) {
+			return c.applyResource(sb, newRestoreJob(sb, restoreCopy))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			switch condition.Type {
+			case batchv1.JobComplete:
+				status.Phase = RestoreScalingUp
+				status.Message = fmt.Sprintf("scaling up deployment %s", sb.Name)
+			case batchv1.JobFailed:
+				c.failRestore(sb, restoreCopy, fmt.Sprintf("job %s failed: %s", jobName, condition.Message))
+			}
+		}
+
+	case RestoreScalingUp:
+		dep, err := c.deploymentLister.Deployments(sb.Namespace).Get(sb.Name)
+		if err != nil {
+			return err
+		}
+
+		if dep.Spec.Replicas == nil || *dep.Spec.Replicas != sb.Spec.Replicas || dep.Status.AvailableReplicas < sb.Spec.Replicas {
+			return nil
+		}
+
+		now := metav1.Now()
+		status.Phase = RestoreSucceeded
+		status.CompletionTime = &now
+		status.Message = ""
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "RestoreSucceeded", "Restored %s (%s)", status.Artifact, restoreCopy.Name)
+	}
+
+	return nil
+}
+
+// the deployment is scaled up again once the restore has failed
+func (c *Controller) failRestore(sb *v1alpha1.SocialBook, restoreCopy *v1alpha1.SocialBookRestore, message string) {
+	now := metav1.Now()
+	restoreCopy.Status.Phase = RestoreFailed
+	restoreCopy.Status.CompletionTime = &now
+	restoreCopy.Status.Message = message
+	c.recorder.Eventf(sb, corev1.EventTypeWarning, "RestoreFailed", "Restore %s failed: %s", restoreCopy.Name, message)
+}
+
+func (c *Controller) updateRestoreStatus(restore *v1alpha1.SocialBookRestore, restoreCopy *v1alpha1.SocialBookRestore) {
+	// no need to update if nothing has changed
+	if equality.Semantic.DeepEqual(restore.Status, restoreCopy.Status) {
+		return
+	}
+
+	_, err := c.customClientset.OperatorsV1alpha1().SocialBookRestores(restoreCopy.Namespace).UpdateStatus(context.Background(), restoreCopy, metav1.UpdateOptions{})
+
+	if err != nil {
+		log.Printf("Error %s while updating status of restore %s", err.Error(), restoreCopy.Name)
+		return
+	}
+
+	log.Printf("Status for restore %s successfully updated", restoreCopy.Name)
+}
+
+// enqueues the SocialBook the restore belongs to
+func (c *Controller) handleRestore(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	restore, ok := obj.(*v1alpha1.SocialBookRestore)
+	if !ok {
+		log.Printf("Invalid object %s", obj)
+		return
+	}
+
+	c.queue.Add(restore.Namespace + "/" + restore.Spec.SocialBook)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestRestore(phase string) *v1alpha1.SocialBookRestore {
+	return &v1alpha1.SocialBookRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sb-restore",
+			Namespace: "dev",
+		},
+		Spec: v1alpha1.SocialBookRestoreSpec{
+			SocialBook: "sb",
+		},
+		Status: v1alpha1.SocialBookRestoreStatus{
+			Phase:    phase,
+			Artifact: "20240101T020000Z.archive.gz",
+		},
+	}
+}
+
+func TestRestorePending(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Backup = &v1alpha1.BackupSpec{
+		Schedule: "0 2 * * *",
+		Volume:   &v1alpha1.VolumeBackupTarget{ClaimName: "backups"},
+	}
+	sb.Status.Backup = &v1alpha1.BackupStatus{LastArtifact: "20240102T020000Z.archive.gz"}
+
+	c := newTestController(t)
+	restore := newTestRestore(RestorePending)
+	restore.Status.Artifact = ""
+
+	if err := c.restore(sb, restore); err != nil {
+		t.Fatal(err)
+	}
+
+	if restore.Status.Phase != RestoreScalingDown || restore.Status.Artifact != "20240102T020000Z.archive.gz" {
+		t.Errorf("expected the last backup to be restored after scaling down, got %s %s", restore.Status.Phase, restore.Status.Artifact)
+	}
+}
+
+func TestRestorePendingWithoutBackup(t *testing.T) {
+	c := newTestController(t)
+	restore := newTestRestore(RestorePending)
+
+	if err := c.restore(newTestSocialBook(), restore); err != nil {
+		t.Fatal(err)
+	}
+
+	if restore.Status.Phase != RestoreFailed {
+		t.Errorf("expected phase %s, got %s", RestoreFailed, restore.Status.Phase)
+	}
+}
+
+func TestRestoreScalingDown(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Backup = &v1alpha1.BackupSpec{
+		Schedule: "0 2 * * *",
+		Volume:   &v1alpha1.VolumeBackupTarget{ClaimName: "backups"},
+	}
+
+	// the job exists already, so it isn't applied again
+	restoreJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sb-restore" + Restore,
+			Namespace: sb.Namespace,
+		},
+	}
+
+	appPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sb.Name + SocialBook + "-abc",
+			Namespace: sb.Namespace,
+			Labels:    map[string]string{"app": sb.Name + SocialBook},
+		},
+	}
+
+	runningBackup := newTestBackupJob(sb, "sb-backup-1", time.Now(), false)
+	runningBackup.Status.Failed = 0
+
+	cases := []struct {
+		name    string
+		objects []runtime.Object
+		phase   string
+	}{
+		{
+			name:    "pods of the app are left",
+			objects: []runtime.Object{restoreJob, appPod},
+			phase:   RestoreScalingDown,
+		},
+		{
+			name:    "a backup is running",
+			objects: []runtime.Object{restoreJob, runningBackup},
+			phase:   RestoreScalingDown,
+		},
+		{
+			name:    "scaled down",
+			objects: []runtime.Object{restoreJob},
+			phase:   RestoreRestoring,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestController(t, tc.objects...)
+			restore := newTestRestore(RestoreScalingDown)
+
+			if err := c.restore(sb, restore); err != nil {
+				t.Fatal(err)
+			}
+
+			if restore.Status.Phase != tc.phase {
+				t.Errorf("expected phase %s, got %s", tc.phase, restore.Status.Phase)
+			}
+		})
+	}
+}
+
+func TestUpdateRestoreStatus(t *testing.T) {
+	c := newTestController(t)
+
+	// the generated fake clientset tracks the restores under the group of the +groupName tag, so the restore is created through it
+	restore := newTestRestore(RestorePending)
+	_, err := c.customClientset.OperatorsV1alpha1().SocialBookRestores("dev").Create(context.Background(), restore, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restoreCopy := restore.DeepCopy()
+	restoreCopy.Status.Phase = RestoreScalingDown
+	c.updateRestoreStatus(restore, restoreCopy)
+
+	updated, err := c.customClientset.OperatorsV1alpha1().SocialBookRestores("dev").Get(context.Background(), "sb-restore", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Status.Phase != RestoreScalingDown {
+		t.Errorf("expected phase %s, got %s", RestoreScalingDown, updated.Status.Phase)
+	}
+}
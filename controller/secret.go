@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sensitive values of a SocialBook, either taken from the spec or from the referenced secrets
+type credentials struct {
+	mongoUsername string
+	mongoPassword string
+	jwtSecret     string
+	password      string
+	stripeApiKey  string
+	mongoKeyFile  string   // shared by the members of the replica set to authenticate each other, only set in replica set mode
+	mongodbUri    string   // uri of the external database, only set when an external database is used
+	mongoCA       string   // ca of the external database, only set when tls is used
+	generated     []string // spec fields whose values were generated by the controller
+	refused       string   // secret whose mongodb credentials were kept instead of the changed ones, empty if they weren't changed
+}
+
+func newSecret(sb *v1alpha1.SocialBook, creds *credentials) *corev1.Secret {
+	secretName := sb.Name + Secret
+	// the credentials are escaped, characters like @ : / would otherwise break the uri
+	userInfo := url.UserPassword(creds.mongoUsername, creds.mongoPassword).String()
+	mongodbUri := "mongodb://" + userInfo + "@" + sb.Name + MongoDB + ":27017"
+
+	// all the members are listed so that the driver fails over to the new primary
+	if replicaSetMode(sb) {
+		mongodbUri = "mongodb://" + userInfo + "@" + strings.Join(replicaSetMembers(sb), ",") + "/?replicaSet=" + ReplicaSetName + "&authSource=admin"
+	}
+
+	if creds.mongodbUri != "" {
+		mongodbUri = creds.mongodbUri
+	}
+
+	if creds.mongoCA != "" {
+		mongodbUri = withDatabaseCA(mongodbUri)
+	}
+
+	// secret holding all the sensitive values
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secretName,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"mongo-root-username": []byte(creds.mongoUsername), // mongo username
+			"mongo-root-password": []byte(creds.mongoPassword), // mongo password
+			"secret":              []byte(creds.jwtSecret),     // any random string (used for jwt token)
+			"stripe-api-key":      []byte(creds.stripeApiKey),  // api key used for payments
+			"user-pwd":            []byte(creds.password),      // password for email id
+			"mongodb-uri":         []byte(mongodbUri),          // contains the mongo credentials
+		},
+	}
+
+	if creds.mongoKeyFile != "" {
+		secret.Data["mongo-keyfile"] = []byte(creds.mongoKeyFile)
+	}
+
+	if creds.mongoCA != "" {
+		secret.Data["mongodb-ca"] = []byte(creds.mongoCA)
+	}
+
+	return secret
+}
+
+// resolves the credentials of the SocialBook, values from the referenced secrets take precedence over the ones in the spec
+func (c *Controller) resolveCredentials(sb *v1alpha1.SocialBook) (*credentials, error) {
+	creds := &credentials{
+		mongoUsername: sb.Spec.MongoUsername,
+		mongoPassword: sb.Spec.MongoPassword,
+		jwtSecret:     sb.Spec.JwtSecret,
+		password:      sb.Spec.Password,
+		stripeApiKey:  sb.Spec.StripeApiKey,
+	}
+
+	var err error
+
+	if ref := sb.Spec.MongoCredentialsSecretRef; ref != nil {
+		usernameKey := ref.UsernameKey
+		if usernameKey == "" {
+			usernameKey = "username"
+		}
+
+		passwordKey := ref.PasswordKey
+		if passwordKey == "" {
+			passwordKey = "password"
+		}
+
+		if creds.mongoUsername, err = c.getSecretValue(sb.Namespace, ref.Name, usernameKey, false); err != nil {
+			return nil, err
+		}
+
+		if creds.mongoPassword, err = c.getSecretValue(sb.Namespace, ref.Name, passwordKey, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref := sb.Spec.JwtSecretRef; ref != nil {
+		if creds.jwtSecret, err = c.getSecretValue(sb.Namespace, ref.Name, ref.Key, isOptional(ref)); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref := sb.Spec.PasswordSecretRef; ref != nil {
+		if creds.password, err = c.getSecretValue(sb.Namespace, ref.Name, ref.Key, isOptional(ref)); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref := sb.Spec.StripeApiKeySecretRef; ref != nil {
+		if creds.stripeApiKey, err = c.getSecretValue(sb.Namespace, ref.Name, ref.Key, isOptional(ref)); err != nil {
+			return nil, err
+		}
+	}
+
+	// the credentials of an external database are part of its uri
+	if external := externalDatabase(sb); external != nil {
+		if creds.mongodbUri, err = c.getSecretValue(sb.Namespace, external.URISecretRef.Name, external.URISecretRef.Key, false); err != nil {
+			return nil, err
+		}
+
+		if ref := external.CASecretRef; ref != nil {
+			if creds.mongoCA, err = c.getSecretValue(sb.Namespace, ref.Name, ref.Key, isOptional(ref)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// mongo only enables authentication when both username and password are set
+	if creds.mongoUsername == "" {
+		creds.mongoUsername = DefaultMongoUsername
+	}
+
+	// values which are not passed are generated once and then read back from the secret on later reconciles
+	if creds.mongoPassword == "" && creds.mongodbUri == "" {
+		if creds.mongoPassword, err = c.getOrGenerateSecretValue(sb, "mongo-root-password"); err != nil {
+			return nil, err
+		}
+		creds.generated = append(creds.generated, "mongoPassword")
+	}
+
+	if creds.jwtSecret == "" {
+		if creds.jwtSecret, err = c.getOrGenerateSecretValue(sb, "secret"); err != nil {
+			return nil, err
+		}
+		creds.generated = append(creds.generated, "jwtSecret")
+	}
+
+	if creds.mongodbUri == "" {
+		if err = c.keepMongoCredentials(sb, creds); err != nil {
+			return nil, err
+		}
+	}
+
+	if replicaSetMode(sb) {
+		if creds.mongoKeyFile, err = c.getOrGenerateSecretValue(sb, "mongo-keyfile"); err != nil {
+			return nil, err
+		}
+	}
+
+	return creds, nil
+}
+
+// mongodb only creates the root user from MONGO_INITDB_* while its data directory is empty, changed credentials would be
+// published in the uri without being changed in the database, so the credentials of the existing secret are kept
+func (c *Controller) keepMongoCredentials(sb *v1alpha1.SocialBook, creds *credentials) error {
+	secret, err := c.getManagedSecret(sb)
+
+	if err != nil || secret == nil {
+		return err
+	}
+
+	username := string(secret.Data["mongo-root-username"])
+	password := string(secret.Data["mongo-root-password"])
+
+	if username == "" || password == "" || (username == creds.mongoUsername && password == creds.mongoPassword) {
+		return nil
+	}
+
+	creds.mongoUsername = username
+	creds.mongoPassword = password
+	creds.refused = secret.Name
+
+	return nil
+}
+
+// returns the value already stored in the secret managed by the controller or generates a new one
+func (c *Controller) getOrGenerateSecretValue(sb *v1alpha1.SocialBook, key string) (string, error) {
+	secret, err := c.getManagedSecret(sb)
+
+	if err != nil {
+		return "", err
+	}
+
+	if secret != nil && len(secret.Data[key]) > 0 {
+		return string(secret.Data[key]), nil
+	}
+
+	return generateRandomString()
+}
+
+// the secret managed by the controller, nil if it doesn't exist yet
+// on a cache miss the secret is read from the api server, the cache may not have seen it yet (right after it was created or
+// while the informer is resyncing) and generating new values then would overwrite the stored ones
+func (c *Controller) getManagedSecret(sb *v1alpha1.SocialBook) (*corev1.Secret, error) {
+	secret, err := c.secretLister.Secrets(sb.Namespace).Get(sb.Name + Secret)
+
+	if errors.IsNotFound(err) {
+		secret, err = c.clientset.CoreV1().Secrets(sb.Namespace).Get(context.Background(), sb.Name+Secret, metav1.GetOptions{})
+	}
+
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// hex encoded so that the value can be used in the mongodb uri without escaping
+func generateRandomString() (string, error) {
+	bytes := make([]byte, 24)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// reads a single key of a secret using the lister
+func (c *Controller) getSecretValue(namespace string, name string, key string, optional bool) (string, error) {
+	secret, err := c.secretLister.Secrets(namespace).Get(name)
+
+	if err != nil {
+		if errors.IsNotFound(err) && optional {
+			return "", nil
+		}
+		return "", err
+	}
+
+	value, ok := secret.Data[key]
+
+	if !ok {
+		if optional {
+			return "", nil
+		}
+		return "", fmt.Errorf("key %s not found in secret %s", key, name)
+	}
+
+	return string(value), nil
+}
+
+func isOptional(ref *corev1.SecretKeySelector) bool {
+	return ref.Optional != nil && *ref.Optional
+}
+
+// checks if the secret is referenced in the spec of the SocialBook
+func referencesSecret(sb *v1alpha1.SocialBook, name string) bool {
+	if sb.Spec.MongoCredentialsSecretRef != nil && sb.Spec.MongoCredentialsSecretRef.Name == name {
+		return true
+	}
+
+	refs := []*corev1.SecretKeySelector{sb.Spec.JwtSecretRef, sb.Spec.PasswordSecretRef, sb.Spec.StripeApiKeySecretRef}
+	if external := externalDatabase(sb); external != nil {
+		refs = append(refs, &external.URISecretRef, external.CASecretRef)
+	}
+
+	for _, ref := range refs {
+		if ref != nil && ref.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
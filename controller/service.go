@@ -23,6 +23,10 @@ func newMongoService(sb *v1alpha1.SocialBook) *corev1.Service {
 
 	// mongo db service
 	svc := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            svcName,
 			Namespace:       sb.Namespace,
@@ -44,11 +48,45 @@ func newMongoService(sb *v1alpha1.SocialBook) *corev1.Service {
 	return svc
 }
 
+// gives the mongodb pods of the statefulset stable dns names, the addresses are published before the pods are ready so that the members can find each other
+func newMongoHeadlessService(sb *v1alpha1.SocialBook) *corev1.Service {
+	svc := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            sb.Name + MongoDB + Headless,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			PublishNotReadyAddresses: true,
+			Selector: map[string]string{
+				"app": sb.Name + MongoDB,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					TargetPort: intstr.FromInt(27017),
+					Port:       27017,
+				},
+			},
+		},
+	}
+
+	return svc
+}
+
 func newSocialBookService(sb *v1alpha1.SocialBook) *corev1.Service {
 	portNumber, _ := strconv.Atoi(sb.Spec.Port)
 	svcName := sb.Name
 
 	svc := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            svcName,
 			Namespace:       sb.Namespace,
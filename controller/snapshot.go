@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the snapshot api is a CRD installed with the csi snapshot controller, so it is used through the dynamic client
+var volumeSnapshotResource = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshots",
+}
+
+// values of SnapshotTriggerLabel
+const (
+	ScheduledSnapshot = "scheduled"
+	OnDemandSnapshot  = "on-demand"
+)
+
+// snapshots are not owned by the SocialBook so that they are kept when it is deleted, they are found with SocialBookLabel
+func newVolumeSnapshot(sb *v1alpha1.SocialBook, claimName string, trigger string, now time.Time) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claimName,
+		},
+	}
+
+	if className := sb.Spec.Snapshot.VolumeSnapshotClassName; className != nil {
+		spec["volumeSnapshotClassName"] = *className
+	}
+
+	metadata := map[string]interface{}{
+		"name":      sb.Name + MongoDB + "-" + now.UTC().Format("20060102150405"),
+		"namespace": sb.Namespace,
+		"labels": map[string]interface{}{
+			SocialBookLabel:      sb.Name,
+			SnapshotTriggerLabel: trigger,
+		},
+	}
+
+	// the request is recorded on the snapshot so that it is taken only once, even if the status update fails afterwards
+	if trigger == OnDemandSnapshot {
+		metadata["annotations"] = map[string]interface{}{
+			SnapshotRequestAnnotation: sb.Spec.Snapshot.Request,
+		}
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": volumeSnapshotResource.GroupVersion().String(),
+			"kind":       "VolumeSnapshot",
+			"metadata":   metadata,
+			"spec":       spec,
+		},
+	}
+}
+
+func snapshotRetention(sb *v1alpha1.SocialBook) int {
+	if sb.Spec.Snapshot.Retention > 0 {
+		return int(sb.Spec.Snapshot.Retention)
+	}
+
+	return DefaultSnapshotRetention
+}
+
+// takes a snapshot of the mongodb volume (of the first member in a replica set) when it is requested or due, removes the scheduled snapshots
+// which are not retained anymore and reports the last snapshot in the status
+func (c *Controller) handleSnapshots(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook) error {
+	if sb.Spec.Snapshot == nil {
+		return nil
+	}
+
+	if externalDatabase(sb) != nil {
+		return fmt.Errorf("snapshot can't be used with database.external, there is no mongodb volume")
+	}
+
+	claimName := c.firstMongoClaimName(sb)
+	pvc, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(claimName)
+	if err != nil || pvc.Status.Phase != corev1.ClaimBound {
+		// the volume is snapshotted once it is bound
+		return nil
+	}
+
+	snapshots := c.dynamicClient.Resource(volumeSnapshotResource).Namespace(sb.Namespace)
+	list, err := snapshots.List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{SocialBookLabel: sb.Name}).String(),
+	})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("the VolumeSnapshot api %s is not installed in the cluster", volumeSnapshotResource.GroupVersion())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// newest first
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		newer, older := items[i].GetCreationTimestamp(), items[j].GetCreationTimestamp()
+		return older.Before(&newer)
+	})
+
+	status := sbCopy.Status.Snapshot
+	if status == nil {
+		status = &v1alpha1.SnapshotStatus{}
+		sbCopy.Status.Snapshot = status
+	}
+
+	if len(items) > 0 {
+		created := items[0].GetCreationTimestamp()
+		readyToUse, _, _ := unstructured.NestedBool(items[0].Object, "status", "readyToUse")
+
+		status.LastSnapshotName = items[0].GetName()
+		status.LastSnapshotTime = &created
+		status.ReadyToUse = readyToUse
+	}
+
+	spec := sb.Spec.Snapshot
+	now := time.Now()
+	key := sb.Namespace + "/" + sb.Name
+
+	// the snapshot of the request may exist while the status wasn't updated
+	// the schedule follows the newest scheduled snapshot, on-demand snapshots don't push it back (like they don't count in the retention)
+	var lastScheduled *metav1.Time
+	for _, item := range items {
+		if spec.Request != "" && item.GetAnnotations()[SnapshotRequestAnnotation] == spec.Request {
+			status.LastRequest = spec.Request
+		}
+
+		if lastScheduled == nil && item.GetLabels()[SnapshotTriggerLabel] == ScheduledSnapshot {
+			created := item.GetCreationTimestamp()
+			lastScheduled = &created
+		}
+	}
+
+	trigger := ""
+	if spec.Request != "" && spec.Request != status.LastRequest {
+		trigger = OnDemandSnapshot
+	} else if spec.Interval != nil {
+		if lastScheduled == nil || !now.Before(lastScheduled.Add(spec.Interval.Duration)) {
+			trigger = ScheduledSnapshot
+		} else {
+			// checked again when the next snapshot is due
+			c.queue.AddAfter(key, lastScheduled.Add(spec.Interval.Duration).Sub(now))
+		}
+	}
+
+	// a new snapshot is only taken once the last one is ready
+	if trigger != "" && len(items) > 0 && !status.ReadyToUse {
+		c.queue.AddAfter(key, RequeueInterval)
+		trigger = ""
+	}
+
+	if trigger != "" {
+		snapshot, err := snapshots.Create(context.Background(), newVolumeSnapshot(sb, claimName, trigger, now), metav1.CreateOptions{FieldManager: FieldManager})
+		if err != nil {
+			return err
+		}
+
+		created := snapshot.GetCreationTimestamp()
+		status.LastSnapshotName = snapshot.GetName()
+		status.LastSnapshotTime = &created
+		status.ReadyToUse = false
+		if trigger == OnDemandSnapshot {
+			status.LastRequest = spec.Request
+		}
+
+		c.recorder.Eventf(sb, corev1.EventTypeNormal, "SnapshotCreated", "Created %s VolumeSnapshot %s of claim %s", trigger, snapshot.GetName(), claimName)
+
+		if spec.Interval != nil {
+			c.queue.AddAfter(key, spec.Interval.Duration)
+		}
+	}
+
+	// the oldest scheduled snapshots beyond the retention are removed
+	kept := snapshotRetention(sb)
+	if trigger == ScheduledSnapshot {
+		// the snapshot which was just created is not in the list
+		kept--
+	}
+
+	scheduled := 0
+	for _, item := range items {
+		if item.GetLabels()[SnapshotTriggerLabel] != ScheduledSnapshot {
+			continue
+		}
+
+		scheduled++
+		if scheduled <= kept {
+			continue
+		}
+
+		err = snapshots.Delete(context.Background(), item.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		log.Printf("Deleted VolumeSnapshot %s of %s, more than %d scheduled snapshots", item.GetName(), sb.Name, snapshotRetention(sb))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// bound claim of the first mongodb member, snapshots are only taken of bound claims
+func newTestMongoClaim(sb *v1alpha1.SocialBook) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MongoDataVolume + "-" + sb.Name + MongoDB + "-0",
+			Namespace: sb.Namespace,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+}
+
+// snapshot created at the given time, ready to use
+func newTestSnapshot(sb *v1alpha1.SocialBook, name string, trigger string, created time.Time) *unstructured.Unstructured {
+	snapshot := newVolumeSnapshot(sb, "claim", trigger, created)
+	snapshot.SetName(name)
+	snapshot.SetCreationTimestamp(metav1.NewTime(created))
+	snapshot.Object["status"] = map[string]interface{}{"readyToUse": true}
+	return snapshot
+}
+
+func listTestSnapshots(t *testing.T, c *Controller) []unstructured.Unstructured {
+	t.Helper()
+
+	list, err := c.dynamicClient.Resource(volumeSnapshotResource).Namespace("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return list.Items
+}
+
+func TestHandleSnapshotsOnDemand(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Snapshot = &v1alpha1.SnapshotSpec{Request: "first"}
+	c := newTestController(t, newTestMongoClaim(sb))
+
+	sbCopy := sb.DeepCopy()
+	if err := c.handleSnapshots(sb, sbCopy); err != nil {
+		t.Fatal(err)
+	}
+
+	items := listTestSnapshots(t, c)
+	if len(items) != 1 {
+		t.Fatalf("expected 1 snapshot, got %d", len(items))
+	}
+
+	if trigger := items[0].GetLabels()[SnapshotTriggerLabel]; trigger != OnDemandSnapshot {
+		t.Errorf("expected trigger %s, got %s", OnDemandSnapshot, trigger)
+	}
+
+	if request := items[0].GetAnnotations()[SnapshotRequestAnnotation]; request != "first" {
+		t.Errorf("expected request annotation first, got %s", request)
+	}
+
+	if sbCopy.Status.Snapshot.LastRequest != "first" {
+		t.Errorf("expected last request first, got %s", sbCopy.Status.Snapshot.LastRequest)
+	}
+}
+
+func TestHandleSnapshotsOnDemandStatusNotUpdated(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Snapshot = &v1alpha1.SnapshotSpec{Request: "first"}
+
+	// the snapshot of the request was created but the status update failed
+	existing := newTestSnapshot(sb, "sb-mongo-1", OnDemandSnapshot, time.Now().Add(-time.Minute))
+	c := newTestController(t, newTestMongoClaim(sb), existing)
+
+	sbCopy := sb.DeepCopy()
+	if err := c.handleSnapshots(sb, sbCopy); err != nil {
+		t.Fatal(err)
+	}
+
+	if items := listTestSnapshots(t, c); len(items) != 1 {
+		t.Fatalf("expected the request to be taken once, got %d snapshots", len(items))
+	}
+
+	if sbCopy.Status.Snapshot.LastRequest != "first" {
+		t.Errorf("expected last request first, got %s", sbCopy.Status.Snapshot.LastRequest)
+	}
+}
+
+func TestHandleSnapshotsRetention(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Snapshot = &v1alpha1.SnapshotSpec{
+		Interval:  &metav1.Duration{Duration: time.Hour},
+		Retention: 2,
+	}
+
+	now := time.Now()
+	c := newTestController(t, newTestMongoClaim(sb),
+		newTestSnapshot(sb, "sb-mongo-1", ScheduledSnapshot, now.Add(-4*time.Minute)),
+		newTestSnapshot(sb, "sb-mongo-2", OnDemandSnapshot, now.Add(-3*time.Minute)),
+		newTestSnapshot(sb, "sb-mongo-3", ScheduledSnapshot, now.Add(-2*time.Minute)),
+		newTestSnapshot(sb, "sb-mongo-4", ScheduledSnapshot, now.Add(-time.Minute)),
+	)
+
+	if err := c.handleSnapshots(sb, sb.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	for _, item := range listTestSnapshots(t, c) {
+		names[item.GetName()] = true
+	}
+
+	// no snapshot is due, the oldest scheduled one is beyond the retention and on-demand snapshots are kept
+	expected := map[string]bool{"sb-mongo-2": true, "sb-mongo-3": true, "sb-mongo-4": true}
+	if len(names) != len(expected) {
+		t.Fatalf("expected snapshots %v, got %v", expected, names)
+	}
+
+	for name := range expected {
+		if !names[name] {
+			t.Errorf("expected snapshot %s to be kept, got %v", name, names)
+		}
+	}
+}
+
+func TestHandleSnapshotsScheduledRetention(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Snapshot = &v1alpha1.SnapshotSpec{
+		Interval:  &metav1.Duration{Duration: time.Hour},
+		Retention: 2,
+	}
+
+	now := time.Now()
+	c := newTestController(t, newTestMongoClaim(sb),
+		newTestSnapshot(sb, "sb-mongo-1", ScheduledSnapshot, now.Add(-3*time.Hour)),
+		newTestSnapshot(sb, "sb-mongo-2", ScheduledSnapshot, now.Add(-2*time.Hour)),
+	)
+
+	if err := c.handleSnapshots(sb, sb.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a snapshot is due, the new one and the newest existing one are retained
+	names := map[string]bool{}
+	for _, item := range listTestSnapshots(t, c) {
+		names[item.GetName()] = true
+	}
+
+	if len(names) != 2 || names["sb-mongo-1"] || !names["sb-mongo-2"] {
+		t.Errorf("expected the new snapshot and sb-mongo-2, got %v", names)
+	}
+}
+
+func TestHandleSnapshotsScheduleIgnoresOnDemand(t *testing.T) {
+	sb := newTestSocialBook()
+	sb.Spec.Snapshot = &v1alpha1.SnapshotSpec{
+		Interval: &metav1.Duration{Duration: time.Hour},
+		Request:  "first",
+	}
+
+	now := time.Now()
+	onDemand := newTestSnapshot(sb, "sb-mongo-2", OnDemandSnapshot, now.Add(-10*time.Minute))
+	onDemand.SetAnnotations(map[string]string{SnapshotRequestAnnotation: "first"})
+
+	c := newTestController(t, newTestMongoClaim(sb),
+		newTestSnapshot(sb, "sb-mongo-1", ScheduledSnapshot, now.Add(-2*time.Hour)),
+		onDemand,
+	)
+
+	if err := c.handleSnapshots(sb, sb.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the scheduled snapshot is due although an on-demand snapshot was taken within the interval
+	scheduled := 0
+	for _, item := range listTestSnapshots(t, c) {
+		if item.GetLabels()[SnapshotTriggerLabel] == ScheduledSnapshot {
+			scheduled++
+		}
+	}
+
+	if scheduled != 2 {
+		t.Errorf("expected a new scheduled snapshot, got %d scheduled snapshots", scheduled)
+	}
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// claimName is the claim mounted by mongodb, when it is empty the claims are created from the volumeClaimTemplates (one per pod)
+func newMongoStatefulSet(sb *v1alpha1.SocialBook, configHash string, claimName string) *appsv1.StatefulSet {
+
+	replicas := mongoReplicas(sb)
+
+	stsName := sb.Name + MongoDB
+	secretName := sb.Name + Secret
+
+	// mongo db statefulset
+	sts := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "StatefulSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            stsName,
+			Namespace:       sb.Namespace,
+			OwnerReferences: setOwnerReference(sb),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: sb.Name + MongoDB + Headless,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": sb.Name + MongoDB,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": sb.Name + MongoDB,
+					},
+					Annotations: map[string]string{
+						ConfigHashAnnotation: configHash,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  sb.Name + MongoDB,
+							Image: mongoImage(sb),
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 27017,
+								},
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "MONGO_INITDB_ROOT_USERNAME",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: secretName,
+											},
+											Key: "mongo-root-username",
+										},
+									},
+								},
+								{
+									Name: "MONGO_INITDB_ROOT_PASSWORD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: secretName,
+											},
+											Key: "mongo-root-password",
+										},
+									},
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      MongoDataVolume,
+									MountPath: "/data/db",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if replicaSetMode(sb) {
+		addReplicaSet(sb, &sts.Spec.Template.Spec)
+	}
+
+	setImagePullOptions(sb, &sts.Spec.Template.Spec)
+
+	if claimName != "" {
+		sts.Spec.Template.Spec.Volumes = append(sts.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: MongoDataVolume,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+				},
+			},
+		})
+
+		return sts
+	}
+
+	sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{
+		*newVolumeClaimTemplate(sb),
+	}
+
+	return sts
+}
+
+// runs mongod with --replSet, the members authenticate each other with the keyfile stored in the secret
+// mongod refuses keyfiles which can be read by other users, so the keyfile is copied by an init container and only made readable by mongodb (uid 999)
+func addReplicaSet(sb *v1alpha1.SocialBook, podSpec *corev1.PodSpec) {
+	var mode int32
+	mode = 0400
+
+	podSpec.Volumes = append(podSpec.Volumes,
+		corev1.Volume{
+			Name: "keyfile-secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: sb.Name + Secret,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  "mongo-keyfile",
+							Path: "keyfile",
+						},
+					},
+					DefaultMode: &mode,
+				},
+			},
+		},
+		corev1.Volume{
+			Name: "keyfile",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	)
+
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		Name:    "keyfile",
+		Image:   mongoImage(sb),
+		Command: []string{"sh", "-c", "cp /keyfile-secret/keyfile /keyfile/keyfile && chown 999:999 /keyfile/keyfile && chmod 400 /keyfile/keyfile"},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "keyfile-secret",
+				MountPath: "/keyfile-secret",
+			},
+			{
+				Name:      "keyfile",
+				MountPath: "/keyfile",
+			},
+		},
+	})
+
+	mongo := &podSpec.Containers[0]
+	mongo.Args = []string{"--replSet", ReplicaSetName, "--bind_ip_all", "--keyFile", "/keyfile/keyfile"}
+	mongo.VolumeMounts = append(mongo.VolumeMounts, corev1.VolumeMount{
+		Name:      "keyfile",
+		MountPath: "/keyfile",
+	})
+}
@@ -0,0 +1,299 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// condition types reported in the status of a SocialBook
+const (
+	ConditionReady             = "Ready"
+	ConditionMongoReady        = "MongoReady"
+	ConditionAppReady          = "AppReady"
+	ConditionDegraded          = "Degraded"
+	ConditionProgressing       = "Progressing"
+	ConditionCredentialsSynced = "CredentialsSynced"
+	ConditionResizeRefused     = "ResizeRefused"
+)
+
+// waiting reasons of containers which won't recover without an intervention
+var failedContainerReasons = map[string]bool{
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// state of one of the components (mongodb or socialbook) of a SocialBook
+type componentState struct {
+	ready       bool
+	progressing bool
+	reason      string // reason of the ready condition
+	message     string // message of the ready condition
+	failed      bool   // true if the pods are failing (crash loop, image pull errors etc.)
+}
+
+// computes the status of the SocialBook from the state of the owned resources, reconcileErr is the error of the current reconcile (if any)
+// returns true if all the components are ready
+func (c *Controller) setStatus(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, reconcileErr error) bool {
+	status := &sbCopy.Status
+	status.ObservedGeneration = sb.Generation
+
+	var mongo componentState
+	if externalDatabase(sb) != nil {
+		status.MongoDBReplicas = v1alpha1.ReplicaStatus{}
+		mongo = externalDatabaseState(status.Database)
+	} else {
+		mongo = c.statefulSetStatus(sb.Namespace, sb.Name+MongoDB, sb.Name+MongoDB, &status.MongoDBReplicas)
+	}
+
+	app := c.deploymentStatus(sb.Namespace, sb.Name, sb.Name+SocialBook, &status.SocialBookReplicas)
+
+	// members are only added to the replica set by the job once their pods are ready
+	if replicaSetMode(sb) && mongo.ready && status.ReplicaSetMembers != mongoReplicas(sb) {
+		mongo.ready = false
+		mongo.reason = "ReplicaSetNotConfigured"
+		mongo.message = fmt.Sprintf("replica set %s has %d/%d members", ReplicaSetName, status.ReplicaSetMembers, mongoReplicas(sb))
+	}
+
+	status.Storage = nil
+	if pvc, err := c.pvcLister.PersistentVolumeClaims(sb.Namespace).Get(c.firstMongoClaimName(sb)); err == nil {
+		status.Storage = storageStatus(pvc)
+
+		// mongodb can't start until its volume is bound
+		if pvc.Status.Phase != corev1.ClaimBound {
+			mongo.ready = false
+			mongo.reason = "VolumeNotBound"
+			mongo.message = fmt.Sprintf("persistent volume claim %s is %s", pvc.Name, pvc.Status.Phase)
+		}
+	}
+
+	// phases which are already marked as failed by the reconcile are not changed
+	if status.MongoDB != Failure {
+		status.MongoDB = phase(mongo)
+	}
+
+	if status.SocialBook != Failure {
+		status.SocialBook = phase(app)
+	}
+
+	c.setCondition(sbCopy, ConditionMongoReady, mongo.ready, mongo.reason, mongo.message)
+	c.setCondition(sbCopy, ConditionAppReady, app.ready, app.reason, app.message)
+
+	if mongo.progressing || app.progressing {
+		c.setCondition(sbCopy, ConditionProgressing, true, "RollingOut", "")
+	} else if status.Storage != nil && status.Storage.Resizing != "" {
+		c.setCondition(sbCopy, ConditionProgressing, true, "VolumeResizing", fmt.Sprintf("persistent volume claim %s: %s", status.Storage.ClaimName, status.Storage.Resizing))
+	} else {
+		c.setCondition(sbCopy, ConditionProgressing, false, "Stable", "")
+	}
+
+	status.LastError = ""
+	switch {
+	case reconcileErr != nil:
+		status.LastError = reconcileErr.Error()
+		c.setCondition(sbCopy, ConditionDegraded, true, "ReconcileError", status.LastError)
+	case mongo.failed:
+		c.setCondition(sbCopy, ConditionDegraded, true, mongo.reason, mongo.message)
+	case app.failed:
+		c.setCondition(sbCopy, ConditionDegraded, true, app.reason, app.message)
+	default:
+		c.setCondition(sbCopy, ConditionDegraded, false, "AsExpected", "")
+	}
+
+	ready := mongo.ready && app.ready && reconcileErr == nil
+	if ready {
+		c.setCondition(sbCopy, ConditionReady, true, "AllComponentsReady", "")
+	} else {
+		c.setCondition(sbCopy, ConditionReady, false, "ComponentsNotReady", "")
+	}
+
+	status.Endpoint = ""
+	if svc, err := c.serviceLister.Services(sb.Namespace).Get(sb.Name); err == nil && len(svc.Spec.Ports) > 0 {
+		status.Endpoint = fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, svc.Spec.Ports[0].Port)
+	}
+
+	return ready
+}
+
+// state of a component computed from its deployment and the pods with the given app label, the replica counts are filled in
+func (c *Controller) deploymentStatus(namespace string, depName string, app string, replicas *v1alpha1.ReplicaStatus) componentState {
+	dep, err := c.deploymentLister.Deployments(namespace).Get(depName)
+
+	if err != nil {
+		*replicas = v1alpha1.ReplicaStatus{}
+		return componentState{reason: "DeploymentNotFound", message: fmt.Sprintf("deployment %s not found", depName)}
+	}
+
+	replicas.Replicas = 1
+	if dep.Spec.Replicas != nil {
+		replicas.Replicas = *dep.Spec.Replicas
+	}
+	replicas.ReadyReplicas = dep.Status.ReadyReplicas
+	replicas.AvailableReplicas = dep.Status.AvailableReplicas
+
+	// the deployment controller has not seen the latest spec yet or old pods are still running
+	progressing := dep.Status.ObservedGeneration < dep.Generation ||
+		dep.Status.UpdatedReplicas < replicas.Replicas ||
+		dep.Status.Replicas > dep.Status.UpdatedReplicas
+
+	return c.rolloutState(namespace, app, "Deployment", progressing, replicas)
+}
+
+// state of a component computed from its statefulset and the pods with the given app label, the replica counts are filled in
+func (c *Controller) statefulSetStatus(namespace string, stsName string, app string, replicas *v1alpha1.ReplicaStatus) componentState {
+	sts, err := c.statefulSetLister.StatefulSets(namespace).Get(stsName)
+
+	if err != nil {
+		*replicas = v1alpha1.ReplicaStatus{}
+		return componentState{reason: "StatefulSetNotFound", message: fmt.Sprintf("statefulset %s not found", stsName)}
+	}
+
+	replicas.Replicas = 1
+	if sts.Spec.Replicas != nil {
+		replicas.Replicas = *sts.Spec.Replicas
+	}
+	replicas.ReadyReplicas = sts.Status.ReadyReplicas
+	replicas.AvailableReplicas = sts.Status.AvailableReplicas
+
+	// the statefulset controller has not seen the latest spec yet or pods of the previous revision are still running
+	progressing := sts.Status.ObservedGeneration < sts.Generation ||
+		sts.Status.UpdatedReplicas < replicas.Replicas ||
+		sts.Status.CurrentRevision != sts.Status.UpdateRevision
+
+	return c.rolloutState(namespace, app, "StatefulSet", progressing, replicas)
+}
+
+// kind is the kind of the workload running the pods, used in the reasons of the ready condition
+func (c *Controller) rolloutState(namespace string, app string, kind string, progressing bool, replicas *v1alpha1.ReplicaStatus) componentState {
+	state := componentState{
+		progressing: progressing,
+		message:     fmt.Sprintf("%d/%d replicas available", replicas.AvailableReplicas, replicas.Replicas),
+	}
+
+	state.ready = !state.progressing && replicas.AvailableReplicas >= replicas.Replicas
+
+	if state.ready {
+		state.reason = kind + "Available"
+		return state
+	}
+
+	state.reason = kind + "NotAvailable"
+
+	// pods which are failing are reported instead of the replica count
+	if reason, message := c.podFailure(namespace, app); reason != "" {
+		state.failed = true
+		state.reason = reason
+		state.message = message
+	}
+
+	return state
+}
+
+// returns the reason and message of the first failing container of the pods with the given app label
+func (c *Controller) podFailure(namespace string, app string) (string, string) {
+	pods, err := c.podLister.Pods(namespace).List(labels.SelectorFromSet(labels.Set{"app": app}))
+
+	if err != nil {
+		return "", ""
+	}
+
+	for _, pod := range pods {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+				return condition.Reason, fmt.Sprintf("pod %s: %s", pod.Name, condition.Message)
+			}
+		}
+
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+
+		for _, containerStatus := range statuses {
+			// a container killed because of memory is usually restarted and ends up in a crash loop, the root cause is reported
+			if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil && terminated.Reason == "OOMKilled" && !containerStatus.Ready {
+				return terminated.Reason, fmt.Sprintf("pod %s: container %s was killed because it ran out of memory", pod.Name, containerStatus.Name)
+			}
+
+			if waiting := containerStatus.State.Waiting; waiting != nil && failedContainerReasons[waiting.Reason] {
+				return waiting.Reason, fmt.Sprintf("pod %s: container %s: %s", pod.Name, containerStatus.Name, waiting.Message)
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// requested and actual size of the volume, the resize conditions of the claim are reported while it is being expanded
+func storageStatus(pvc *corev1.PersistentVolumeClaim) *v1alpha1.StorageStatus {
+	storage := &v1alpha1.StorageStatus{ClaimName: pvc.Name}
+
+	if requested, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		storage.Requested = &requested
+	}
+
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		storage.Capacity = &capacity
+	}
+
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		// the file system is resized by the kubelet once the volume has been expanded
+		if condition.Type == corev1.PersistentVolumeClaimResizing || condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending {
+			storage.Resizing = string(condition.Type)
+		}
+	}
+
+	return storage
+}
+
+// a refused change of the mongodb credentials is kept in a condition until the credentials match the database again, so that
+// the referenced secret and the credentials used by the pods differing stays visible, the event is only recorded when it's refused first
+func (c *Controller) setCredentialsCondition(sb *v1alpha1.SocialBook, sbCopy *v1alpha1.SocialBook, creds *credentials) {
+	if creds.refused == "" {
+		c.setCondition(sbCopy, ConditionCredentialsSynced, true, "AsExpected", "")
+		return
+	}
+
+	message := fmt.Sprintf("MongoDB credentials can't be changed once the database is initialised, the credentials of secret %s are kept", creds.refused)
+	if !meta.IsStatusConditionFalse(sb.Status.Conditions, ConditionCredentialsSynced) {
+		c.recorder.Event(sb, corev1.EventTypeWarning, "CredentialsChangeRefused", message)
+	}
+
+	c.setCondition(sbCopy, ConditionCredentialsSynced, false, "CredentialsChangeRefused", message)
+}
+
+func (c *Controller) setCondition(sbCopy *v1alpha1.SocialBook, conditionType string, value bool, reason string, message string) {
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: sbCopy.Generation,
+	}
+
+	if value {
+		condition.Status = metav1.ConditionTrue
+	}
+
+	meta.SetStatusCondition(&sbCopy.Status.Conditions, condition)
+}
+
+func phase(state componentState) string {
+	if state.ready {
+		return Success
+	}
+	if state.failed {
+		return Failure
+	}
+	return Pending
+}
@@ -1,25 +1,83 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	kubeInformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 
 	"github.com/ashwin901/social-book-operator/controller"
 	"github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned"
 	"github.com/ashwin901/social-book-operator/pkg/client/informers/externalversions"
+	informers "github.com/ashwin901/social-book-operator/pkg/client/informers/externalversions/ashwin901.operators/v1alpha1"
 )
 
 func main() {
 
 	configFile := flag.String("config", "/.kube/config", "kube config file path")
+	metricsAddr := flag.String("metrics-addr", ":8080", "address on which the prometheus metrics are served")
+	healthAddr := flag.String("health-addr", ":8081", "address on which the /healthz and /readyz probes are served")
+	leaderElect := flag.Bool("leader-elect", false, "enable leader election, required when running more than one replica")
+	leaseName := flag.String("leader-elect-lease-name", "social-book-operator", "name of the lease used for leader election")
+	leaseNamespace := flag.String("leader-elect-namespace", "", "namespace of the lease used for leader election (defaults to the namespace of the pod)")
+	leaseIdentity := flag.String("leader-elect-identity", "", "identity of this replica in the leader election (defaults to the hostname)")
+	leaseDuration := flag.Duration("leader-elect-lease-duration", 15*time.Second, "duration non leader replicas wait before trying to acquire the lease")
+	renewDeadline := flag.Duration("leader-elect-renew-deadline", 10*time.Second, "duration the leader retries renewing the lease before giving up leadership")
+	retryPeriod := flag.Duration("leader-elect-retry-period", 2*time.Second, "duration between attempts to acquire or renew the lease")
+	workers := flag.Int("workers", 2, "number of SocialBooks reconciled in parallel")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "time to wait for running reconciles to finish on SIGTERM/SIGINT")
+	selector := flag.String("selector", "", "label selector of the SocialBooks reconciled by this instance, used to shard SocialBooks across several instances (defaults to all SocialBooks)")
+	watchNamespaces := flag.String("watch-namespaces", "", "comma separated list of namespaces in which SocialBooks are reconciled (defaults to all namespaces)")
+	defaultImage := flag.String("default-image", controller.Image, "image of socialbook used when spec.image is not set, for example to use a registry mirror")
+	defaultMongoImage := flag.String("default-mongo-image", controller.MongoImage, "image of mongodb and the mongodb tools used when spec.mongo.image is not set")
+	defaultBackupUploadImage := flag.String("default-backup-upload-image", controller.BackupUploadImage, "image of the minio client used to upload and download the backups of an s3 target")
 	flag.Parse()
 
+	if *workers < 1 {
+		log.Printf("--workers should be at least 1")
+		return
+	}
+
+	// leaderelection.RunOrDie panics on invalid durations
+	if *leaderElect && *leaseDuration <= *renewDeadline {
+		log.Printf("--leader-elect-lease-duration should be greater than --leader-elect-renew-deadline")
+		return
+	}
+
+	if *leaderElect && *renewDeadline <= time.Duration(leaderelection.JitterFactor*float64(*retryPeriod)) {
+		log.Printf("--leader-elect-renew-deadline should be greater than %.1f times --leader-elect-retry-period", leaderelection.JitterFactor)
+		return
+	}
+
+	if err := controller.ValidateMongoImage(*defaultMongoImage); err != nil {
+		log.Printf("Invalid --default-mongo-image: %s", err.Error())
+		return
+	}
+
+	socialBookSelector, err := labels.Parse(*selector)
+
+	if err != nil {
+		log.Printf("Error %s while parsing --selector %s", err.Error(), *selector)
+		return
+	}
+
 	config, err := clientcmd.BuildConfigFromFlags("", *configFile)
 
 	if err != nil {
@@ -50,17 +108,199 @@ func main() {
 		return
 	}
 
-	ch := make(chan struct{})
-	factory := kubeInformers.NewSharedInformerFactory(clientset, 10*time.Minute)
-	customFactory := externalversions.NewSharedInformerFactory(customClientset, 10*time.Minute)
+	// dynamic client used for the VolumeSnapshot api, which has no typed client in client-go
+	dynamicClient, err := dynamic.NewForConfig(config)
+
+	if err != nil {
+		log.Printf("Error %s while creating dynamic client", err.Error())
+		return
+	}
+
+	// the stop channel is closed on SIGTERM/SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	ch := ctx.Done()
+
+	// one pair of informer factories for every watched namespace, so that only namespaced permissions are required
+	var factories []kubeInformers.SharedInformerFactory
+	var customFactories []externalversions.SharedInformerFactory
+	var socialBookInformers []informers.SocialBookInformer
+	var restoreInformers []informers.SocialBookRestoreInformer
+
+	// only the SocialBooks of this shard are listed and watched, the resources owned by them are filtered by the controller
+	shard := func(options *metav1.ListOptions) {
+		options.LabelSelector = socialBookSelector.String()
+	}
+
+	namespaces := parseNamespaces(*watchNamespaces)
+
+	for _, namespace := range namespaces {
+		factory := kubeInformers.NewSharedInformerFactoryWithOptions(clientset, 10*time.Minute, kubeInformers.WithNamespace(namespace))
+		customFactory := externalversions.NewSharedInformerFactoryWithOptions(customClientset, 10*time.Minute, externalversions.WithNamespace(namespace), externalversions.WithTweakListOptions(shard))
+
+		factories = append(factories, factory)
+		customFactories = append(customFactories, customFactory)
+		socialBookInformers = append(socialBookInformers, customFactory.Operators().V1alpha1().SocialBooks())
+
+		// restores are not labelled with the shard, the controller ignores the restores of SocialBooks of other shards
+		restoreFactory := externalversions.NewSharedInformerFactoryWithOptions(customClientset, 10*time.Minute, externalversions.WithNamespace(namespace))
+		customFactories = append(customFactories, restoreFactory)
+		restoreInformers = append(restoreInformers, restoreFactory.Operators().V1alpha1().SocialBookRestores())
+	}
+
+	// images used for the SocialBooks which don't set them in the spec
+	controller.DefaultImage = *defaultImage
+	controller.DefaultMongoImage = *defaultMongoImage
+	controller.DefaultBackupUploadImage = *defaultBackupUploadImage
 
 	// initializing controller
-	controller := controller.NewController(clientset, customClientset, customFactory.Operators().V1alpha1().SocialBooks(), factory)
+	controller := controller.NewController(clientset, customClientset, dynamicClient, socialBookInformers, restoreInformers, factories, socialBookSelector, namespaces[0] == metav1.NamespaceAll)
+
+	// serving prometheus metrics
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+
+		if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+			log.Printf("Error %s while serving metrics on %s", err.Error(), *metricsAddr)
+		}
+	}()
+
+	// serving liveness and readiness probes
+	go func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", healthHandler(controller.Healthy))
+		mux.HandleFunc("/readyz", healthHandler(controller.Ready))
+
+		if err := http.ListenAndServe(*healthAddr, mux); err != nil {
+			log.Printf("Error %s while serving health probes on %s", err.Error(), *healthAddr)
+		}
+	}()
 
 	// initialising all the requested informers
-	customFactory.Start(ch)
-	factory.Start(ch)
+	for _, customFactory := range customFactories {
+		customFactory.Start(ch)
+	}
+
+	for _, factory := range factories {
+		factory.Start(ch)
+	}
+
+	if !*leaderElect {
+		// start the controller
+		controller.Run(*workers, *shutdownTimeout, ch)
+		return
+	}
+
+	identity := *leaseIdentity
+	if identity == "" {
+		identity, err = os.Hostname()
+		if err != nil {
+			log.Printf("Error %s while getting the hostname", err.Error())
+			return
+		}
+	}
+
+	namespace := *leaseNamespace
+	if namespace == "" {
+		namespace = podNamespace()
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      *leaseName,
+			Namespace: namespace,
+		},
+		Client: clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	// the leader election is stopped only after the controller has stopped, so that the lease is never released while reconciles are running
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	var leading atomic.Bool
+
+	go func() {
+		<-ch
+		if !leading.Load() {
+			cancelLeader()
+		}
+	}()
 
-	// start the controller
-	controller.Run(ch)
+	// only the leader runs the controller, other replicas keep their caches warm and take over if the leader is lost
+	leaderelection.RunOrDie(leaderCtx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            *leaseName,
+		LeaseDuration:   *leaseDuration,
+		RenewDeadline:   *renewDeadline,
+		RetryPeriod:     *retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(_ context.Context) {
+				leading.Store(true)
+				log.Printf("%s acquired the lease %s/%s", identity, namespace, *leaseName)
+				controller.Run(*workers, *shutdownTimeout, ch)
+				cancelLeader()
+			},
+			OnStoppedLeading: func() {
+				// stopped because of a signal
+				if leaderCtx.Err() != nil {
+					if leading.Load() {
+						log.Printf("%s released the lease %s/%s", identity, namespace, *leaseName)
+					}
+					return
+				}
+				// exiting so that the controller is never run by two replicas at the same time
+				log.Fatalf("%s lost the lease %s/%s", identity, namespace, *leaseName)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					log.Printf("%s is the leader", leader)
+				}
+			},
+		},
+	})
+}
+
+// namespaces passed to --watch-namespaces, an empty list means all namespaces
+func parseNamespaces(value string) []string {
+	var namespaces []string
+	seen := map[string]bool{}
+
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+
+	if len(namespaces) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+
+	return namespaces
+}
+
+// namespace of the pod the operator is running in, "default" when running outside the cluster
+func podNamespace() string {
+	if ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+
+	return "default"
+}
+
+// responds with 500 and the error if the check fails
+func healthHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}
 }
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNamespaces(t *testing.T) {
+	cases := map[string][]string{
+		"":                {""},
+		" , ":             {""},
+		"dev":             {"dev"},
+		"dev, prod ,dev,": {"dev", "prod"},
+	}
+
+	for value, expected := range cases {
+		if namespaces := parseNamespaces(value); !reflect.DeepEqual(namespaces, expected) {
+			t.Errorf("parseNamespaces(%q): expected %v, got %v", value, expected, namespaces)
+		}
+	}
+}
@@ -25,7 +25,7 @@ func init() {
 }
 
 func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion, &SocialBook{}, &SocialBookList{})
+	scheme.AddKnownTypes(SchemeGroupVersion, &SocialBook{}, &SocialBookList{}, &SocialBookRestore{}, &SocialBookRestoreList{})
 
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -29,6 +29,7 @@ import (
 type OperatorsV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	SocialBooksGetter
+	SocialBookRestoresGetter
 }
 
 // OperatorsV1alpha1Client is used to interact with features provided by the operators group.
@@ -40,6 +41,10 @@ func (c *OperatorsV1alpha1Client) SocialBooks(namespace string) SocialBookInterf
 	return newSocialBooks(c, namespace)
 }
 
+func (c *OperatorsV1alpha1Client) SocialBookRestores(namespace string) SocialBookRestoreInterface {
+	return newSocialBookRestores(c, namespace)
+}
+
 // NewForConfig creates a new OperatorsV1alpha1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
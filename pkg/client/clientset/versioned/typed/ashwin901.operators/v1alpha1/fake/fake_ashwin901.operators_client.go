@@ -32,6 +32,10 @@ func (c *FakeOperatorsV1alpha1) SocialBooks(namespace string) v1alpha1.SocialBoo
 	return &FakeSocialBooks{c, namespace}
 }
 
+func (c *FakeOperatorsV1alpha1) SocialBookRestores(namespace string) v1alpha1.SocialBookRestoreInterface {
+	return &FakeSocialBookRestores{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeOperatorsV1alpha1) RESTClient() rest.Interface {
@@ -0,0 +1,142 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeSocialBookRestores implements SocialBookRestoreInterface
+type FakeSocialBookRestores struct {
+	Fake *FakeOperatorsV1alpha1
+	ns   string
+}
+
+var socialbookrestoresResource = schema.GroupVersionResource{Group: "operators", Version: "v1alpha1", Resource: "socialbookrestores"}
+
+var socialbookrestoresKind = schema.GroupVersionKind{Group: "operators", Version: "v1alpha1", Kind: "SocialBookRestore"}
+
+// Get takes name of the socialBookRestore, and returns the corresponding socialBookRestore object, and an error if there is any.
+func (c *FakeSocialBookRestores) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(socialbookrestoresResource, c.ns, name), &v1alpha1.SocialBookRestore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SocialBookRestore), err
+}
+
+// List takes label and field selectors, and returns the list of SocialBookRestores that match those selectors.
+func (c *FakeSocialBookRestores) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.SocialBookRestoreList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(socialbookrestoresResource, socialbookrestoresKind, c.ns, opts), &v1alpha1.SocialBookRestoreList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.SocialBookRestoreList{ListMeta: obj.(*v1alpha1.SocialBookRestoreList).ListMeta}
+	for _, item := range obj.(*v1alpha1.SocialBookRestoreList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested socialBookRestores.
+func (c *FakeSocialBookRestores) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(socialbookrestoresResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a socialBookRestore and creates it.  Returns the server's representation of the socialBookRestore, and an error, if there is any.
+func (c *FakeSocialBookRestores) Create(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.CreateOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(socialbookrestoresResource, c.ns, socialBookRestore), &v1alpha1.SocialBookRestore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SocialBookRestore), err
+}
+
+// Update takes the representation of a socialBookRestore and updates it. Returns the server's representation of the socialBookRestore, and an error, if there is any.
+func (c *FakeSocialBookRestores) Update(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(socialbookrestoresResource, c.ns, socialBookRestore), &v1alpha1.SocialBookRestore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SocialBookRestore), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeSocialBookRestores) UpdateStatus(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (*v1alpha1.SocialBookRestore, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(socialbookrestoresResource, "status", c.ns, socialBookRestore), &v1alpha1.SocialBookRestore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SocialBookRestore), err
+}
+
+// Delete takes name of the socialBookRestore and deletes it. Returns an error if one occurs.
+func (c *FakeSocialBookRestores) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(socialbookrestoresResource, c.ns, name, opts), &v1alpha1.SocialBookRestore{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeSocialBookRestores) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(socialbookrestoresResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.SocialBookRestoreList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched socialBookRestore.
+func (c *FakeSocialBookRestores) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.SocialBookRestore, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(socialbookrestoresResource, c.ns, name, pt, data, subresources...), &v1alpha1.SocialBookRestore{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.SocialBookRestore), err
+}
@@ -0,0 +1,195 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	scheme "github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// SocialBookRestoresGetter has a method to return a SocialBookRestoreInterface.
+// A group's client should implement this interface.
+type SocialBookRestoresGetter interface {
+	SocialBookRestores(namespace string) SocialBookRestoreInterface
+}
+
+// SocialBookRestoreInterface has methods to work with SocialBookRestore resources.
+type SocialBookRestoreInterface interface {
+	Create(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.CreateOptions) (*v1alpha1.SocialBookRestore, error)
+	Update(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (*v1alpha1.SocialBookRestore, error)
+	UpdateStatus(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (*v1alpha1.SocialBookRestore, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.SocialBookRestore, error)
+	List(ctx context.Context, opts v1.ListOptions) (*v1alpha1.SocialBookRestoreList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.SocialBookRestore, err error)
+	SocialBookRestoreExpansion
+}
+
+// socialBookRestores implements SocialBookRestoreInterface
+type socialBookRestores struct {
+	client rest.Interface
+	ns     string
+}
+
+// newSocialBookRestores returns a SocialBookRestores
+func newSocialBookRestores(c *OperatorsV1alpha1Client, namespace string) *socialBookRestores {
+	return &socialBookRestores{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the socialBookRestore, and returns the corresponding socialBookRestore object, and an error if there is any.
+func (c *socialBookRestores) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	result = &v1alpha1.SocialBookRestore{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of SocialBookRestores that match those selectors.
+func (c *socialBookRestores) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.SocialBookRestoreList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1alpha1.SocialBookRestoreList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested socialBookRestores.
+func (c *socialBookRestores) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a socialBookRestore and creates it.  Returns the server's representation of the socialBookRestore, and an error, if there is any.
+func (c *socialBookRestores) Create(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.CreateOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	result = &v1alpha1.SocialBookRestore{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(socialBookRestore).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a socialBookRestore and updates it. Returns the server's representation of the socialBookRestore, and an error, if there is any.
+func (c *socialBookRestores) Update(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	result = &v1alpha1.SocialBookRestore{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		Name(socialBookRestore.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(socialBookRestore).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *socialBookRestores) UpdateStatus(ctx context.Context, socialBookRestore *v1alpha1.SocialBookRestore, opts v1.UpdateOptions) (result *v1alpha1.SocialBookRestore, err error) {
+	result = &v1alpha1.SocialBookRestore{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		Name(socialBookRestore.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(socialBookRestore).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the socialBookRestore and deletes it. Returns an error if one occurs.
+func (c *socialBookRestores) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *socialBookRestores) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched socialBookRestore.
+func (c *socialBookRestores) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.SocialBookRestore, err error) {
+	result = &v1alpha1.SocialBookRestore{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("socialbookrestores").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
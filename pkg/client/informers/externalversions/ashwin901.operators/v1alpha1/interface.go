@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// SocialBooks returns a SocialBookInformer.
 	SocialBooks() SocialBookInformer
+	// SocialBookRestores returns a SocialBookRestoreInformer.
+	SocialBookRestores() SocialBookRestoreInformer
 }
 
 type version struct {
@@ -43,3 +45,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) SocialBooks() SocialBookInformer {
 	return &socialBookInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// SocialBookRestores returns a SocialBookRestoreInformer.
+func (v *version) SocialBookRestores() SocialBookRestoreInformer {
+	return &socialBookRestoreInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"context"
+	time "time"
+
+	ashwin901operatorsv1alpha1 "github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	versioned "github.com/ashwin901/social-book-operator/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/ashwin901/social-book-operator/pkg/client/informers/externalversions/internalinterfaces"
+	v1alpha1 "github.com/ashwin901/social-book-operator/pkg/client/listers/ashwin901.operators/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// SocialBookRestoreInformer provides access to a shared informer and lister for
+// SocialBookRestores.
+type SocialBookRestoreInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1alpha1.SocialBookRestoreLister
+}
+
+type socialBookRestoreInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewSocialBookRestoreInformer constructs a new informer for SocialBookRestore type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewSocialBookRestoreInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredSocialBookRestoreInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredSocialBookRestoreInformer constructs a new informer for SocialBookRestore type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredSocialBookRestoreInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.OperatorsV1alpha1().SocialBookRestores(namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.OperatorsV1alpha1().SocialBookRestores(namespace).Watch(context.TODO(), options)
+			},
+		},
+		&ashwin901operatorsv1alpha1.SocialBookRestore{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *socialBookRestoreInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredSocialBookRestoreInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *socialBookRestoreInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&ashwin901operatorsv1alpha1.SocialBookRestore{}, f.defaultInformer)
+}
+
+func (f *socialBookRestoreInformer) Lister() v1alpha1.SocialBookRestoreLister {
+	return v1alpha1.NewSocialBookRestoreLister(f.Informer().GetIndexer())
+}
@@ -55,6 +55,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=operators, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("socialbooks"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Operators().V1alpha1().SocialBooks().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("socialbookrestores"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Operators().V1alpha1().SocialBookRestores().Informer()}, nil
 
 	}
 
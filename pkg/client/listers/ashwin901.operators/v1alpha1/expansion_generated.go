@@ -25,3 +25,11 @@ type SocialBookListerExpansion interface{}
 // SocialBookNamespaceListerExpansion allows custom methods to be added to
 // SocialBookNamespaceLister.
 type SocialBookNamespaceListerExpansion interface{}
+
+// SocialBookRestoreListerExpansion allows custom methods to be added to
+// SocialBookRestoreLister.
+type SocialBookRestoreListerExpansion interface{}
+
+// SocialBookRestoreNamespaceListerExpansion allows custom methods to be added to
+// SocialBookRestoreNamespaceLister.
+type SocialBookRestoreNamespaceListerExpansion interface{}
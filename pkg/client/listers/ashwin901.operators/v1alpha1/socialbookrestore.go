@@ -0,0 +1,99 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/ashwin901/social-book-operator/pkg/apis/ashwin901.operators/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SocialBookRestoreLister helps list SocialBookRestores.
+// All objects returned here must be treated as read-only.
+type SocialBookRestoreLister interface {
+	// List lists all SocialBookRestores in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.SocialBookRestore, err error)
+	// SocialBookRestores returns an object that can list and get SocialBookRestores.
+	SocialBookRestores(namespace string) SocialBookRestoreNamespaceLister
+	SocialBookRestoreListerExpansion
+}
+
+// socialBookRestoreLister implements the SocialBookRestoreLister interface.
+type socialBookRestoreLister struct {
+	indexer cache.Indexer
+}
+
+// NewSocialBookRestoreLister returns a new SocialBookRestoreLister.
+func NewSocialBookRestoreLister(indexer cache.Indexer) SocialBookRestoreLister {
+	return &socialBookRestoreLister{indexer: indexer}
+}
+
+// List lists all SocialBookRestores in the indexer.
+func (s *socialBookRestoreLister) List(selector labels.Selector) (ret []*v1alpha1.SocialBookRestore, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.SocialBookRestore))
+	})
+	return ret, err
+}
+
+// SocialBookRestores returns an object that can list and get SocialBookRestores.
+func (s *socialBookRestoreLister) SocialBookRestores(namespace string) SocialBookRestoreNamespaceLister {
+	return socialBookRestoreNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// SocialBookRestoreNamespaceLister helps list and get SocialBookRestores.
+// All objects returned here must be treated as read-only.
+type SocialBookRestoreNamespaceLister interface {
+	// List lists all SocialBookRestores in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1alpha1.SocialBookRestore, err error)
+	// Get retrieves the SocialBookRestore from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1alpha1.SocialBookRestore, error)
+	SocialBookRestoreNamespaceListerExpansion
+}
+
+// socialBookRestoreNamespaceLister implements the SocialBookRestoreNamespaceLister
+// interface.
+type socialBookRestoreNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all SocialBookRestores in the indexer for a given namespace.
+func (s socialBookRestoreNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.SocialBookRestore, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.SocialBookRestore))
+	})
+	return ret, err
+}
+
+// Get retrieves the SocialBookRestore from the indexer for a given namespace and name.
+func (s socialBookRestoreNamespaceLister) Get(name string) (*v1alpha1.SocialBookRestore, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("socialbookrestore"), name)
+	}
+	return obj.(*v1alpha1.SocialBookRestore), nil
+}